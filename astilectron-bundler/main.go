@@ -18,9 +18,11 @@ var (
 	astilectronPath   = flag.String("a", "", "the astilectron path")
 	configurationPath = flag.String("c", "", "the configuration path")
 	darwin            = flag.Bool("d", false, "if set, will add darwin/amd64 to the environments")
+	dev               = flag.Bool("dev", false, "if set, bd will generate a bind file reading resources from disk")
 	linux             = flag.Bool("l", false, "if set, will add linux/amd64 to the environments")
 	windows           = flag.Bool("w", false, "if set, will add windows/amd64 to the environments")
 	environmentFilter = flag.String("e", "", "if set, will only match environments matching pattern.")
+	force             = flag.Bool("f", false, "if set, will bundle environments even if their inputs haven't changed")
 )
 
 func main() {
@@ -80,6 +82,16 @@ func main() {
 		c.EnvironmentFilter = *environmentFilter
 	}
 
+	// Dev
+	if *dev {
+		c.BindDev = true
+	}
+
+	// Force
+	if *force {
+		c.Force = true
+	}
+
 	// Build bundler
 	var b *astibundler.Bundler
 	if b, err = astibundler.New(c); err != nil {
@@ -89,21 +101,30 @@ func main() {
 	// Handle signals
 	b.HandleSignals()
 
+	// Render download progress
+	// The bar is cleared explicitly before fatal errors since astilog.Fatal exits without running deferred functions
+	var p = newProgress()
+	b.OnDownloadProgress = p.update
+	defer p.done()
+
 	// Switch on subcommand
 	switch s {
 	case "bd":
 		// Bind data
 		if err = b.BindData(runtime.GOOS, runtime.GOARCH, ""); err != nil {
+			p.done()
 			astilog.Fatal(errors.Wrap(err, "binding data failed"))
 		}
 	case "cc":
 		// Clear cache
 		if err = b.ClearCache(); err != nil {
+			p.done()
 			astilog.Fatal(errors.Wrap(err, "clearing cache failed"))
 		}
 	default:
 		// Bundle
 		if err = b.Bundle(); err != nil {
+			p.done()
 			astilog.Fatal(errors.Wrap(err, "bundling failed"))
 		}
 	}
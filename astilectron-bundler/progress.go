@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilectron-bundler"
+)
+
+// Progress rendering
+const (
+	progressBarWidth     = 30
+	progressLineInterval = 10 * time.Second
+)
+
+// progress renders the progress of the downloads, as a progress bar on TTYs and as periodic lines otherwise so
+// that CIs don't consider the job as stalled
+type progress struct {
+	last  time.Time
+	m     *sync.Mutex
+	ps    map[string]astibundler.DownloadProgress // Indexed by URL
+	tty   bool
+	w     io.Writer
+	width int // Width of the last rendered bar
+}
+
+// newProgress builds a progress renderer writing to stdout
+func newProgress() *progress {
+	var tty bool
+	if fi, err := os.Stdout.Stat(); err == nil {
+		tty = fi.Mode()&os.ModeCharDevice != 0
+	}
+	return &progress{
+		m:   &sync.Mutex{},
+		ps:  make(map[string]astibundler.DownloadProgress),
+		tty: tty,
+		w:   os.Stdout,
+	}
+}
+
+// update handles a progress update. It's safe for concurrent use.
+func (p *progress) update(dp astibundler.DownloadProgress) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// Update downloads
+	if dp.Done {
+		delete(p.ps, dp.URL)
+	} else {
+		p.ps[dp.URL] = dp
+	}
+
+	// No TTY
+	if !p.tty {
+		if dp.Done {
+			fmt.Fprintln(p.w, dp)
+		} else if time.Since(p.last) >= progressLineInterval {
+			p.last = time.Now()
+			for _, u := range p.urls() {
+				fmt.Fprintln(p.w, p.ps[u])
+			}
+		}
+		return
+	}
+
+	// Print done downloads above the bar
+	p.clear()
+	if dp.Done {
+		fmt.Fprintln(p.w, dp)
+	}
+
+	// Render bar
+	if len(p.ps) > 0 {
+		var s = p.bar()
+		fmt.Fprint(p.w, s)
+		p.width = len(s)
+	}
+}
+
+// urls returns the URLs of the downloads in progress, sorted
+func (p *progress) urls() (us []string) {
+	for u := range p.ps {
+		us = append(us, u)
+	}
+	sort.Strings(us)
+	return
+}
+
+// clear clears the last rendered bar
+func (p *progress) clear() {
+	if p.width > 0 {
+		fmt.Fprintf(p.w, "\r%s\r", strings.Repeat(" ", p.width))
+		p.width = 0
+	}
+}
+
+// bar returns the progress bar of the downloads in progress
+func (p *progress) bar() string {
+	// Aggregate downloads
+	var a = astibundler.DownloadProgress{Name: p.ps[p.urls()[0]].Name}
+	if len(p.ps) > 1 {
+		a.Name = fmt.Sprintf("%d files", len(p.ps))
+	}
+	for _, dp := range p.ps {
+		a.Downloaded += dp.Downloaded
+		if dp.Total < 0 || a.Total < 0 {
+			a.Total = -1
+		} else {
+			a.Total += dp.Total
+		}
+		a.Throughput += dp.Throughput
+		if dp.ETA > a.ETA {
+			a.ETA = dp.ETA
+		}
+	}
+
+	// Build bar
+	var filled int
+	if pc := a.Percent(); pc >= 0 {
+		filled = int(pc / 100 * progressBarWidth)
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+	}
+	return fmt.Sprintf("\r[%s%s] %s", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), a)
+}
+
+// done clears the bar once bundling is done
+func (p *progress) done() {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.tty {
+		p.clear()
+	}
+}
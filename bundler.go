@@ -1,16 +1,26 @@
 package astibundler
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"go/build/constraint"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -62,42 +72,241 @@ type Configuration struct {
 	// Override tags for bind.go
 	BindTags string `json:"bind_tags"`
 
+	// Folders whose files are bound as resources. Default is the "resources" folder of the input path
+	ResourcesPaths []ConfigurationResources `json:"resources_paths"`
+
+	// Glob patterns, relative to the resources folder, of the resources to bind. Default is all of them
+	ResourcesInclude []string `json:"resources_include"`
+
+	// Glob patterns, relative to the resources folder, of the resources not to bind. Patterns listed in the
+	// .bundlerignore file of the input path are added
+	ResourcesExclude []string `json:"resources_exclude"`
+
+	// Compression of the bound files
+	BindCompression ConfigurationBindCompression `json:"bind_compression"`
+
+	// Generate a bind.go reading resources from disk at runtime when only binding data (development only)
+	BindDev bool `json:"bind_dev"`
+
+	// Backend generating bind.go: "bindata" (default) uses go-bindata, "embed" uses go:embed (requires go 1.18+)
+	BindBackend string `json:"bind_backend"`
+
+	// Names of environment variables passed to go build on top of the default ones (PATH, GOPATH, HOME, GOCACHE,
+	// CGO_ENABLED, CC, proxies, etc.). Use "*" to pass the whole environment
+	BuildEnvPassthrough []string `json:"build_env_passthrough"`
+
+	// Go build options applied to all environments
+	ConfigurationBuild
+
+	// The path of the main package relative to the input path.
+	// Best is to leave it empty. Default value is the input path
+	BuildPath string `json:"build_path"`
+
 	//!\\ DEBUG ONLY
 	AstilectronPath string `json:"astilectron_path"` // when making changes to astilectron
 
 	// Environment filter
 	EnvironmentFilter string `json:"environment_filter"`
+
+	// How vendor zips are shipped: "bind" (default) binds them with the resources, "payload" appends them to the
+	// binary which must then use NewPayloadProvisioner and "download" only binds the URL and SHA-256 of the electron
+	// zip which is downloaded at first run by NewDownloadProvisioner
+	VendorMode string `json:"vendor_mode"`
+
+	// Names vendor files are bound under. They must match the ProvisionerOptions used at runtime
+	VendorAssets ConfigurationVendorAssets `json:"vendor_assets"`
+
+	// Codec vendor zips are repacked with before they're bound: "zstd" or "xz" repack them as compressed tars which
+	// must then be provisioned by NewRepackedProvisioner. Default is to bind the zips as is
+	VendorCodec string `json:"vendor_codec"`
+
+	// Files removed from the electron zip before it's bound. Not applied in "download" vendor mode
+	ElectronPrune ConfigurationElectronPrune `json:"electron_prune"`
+
+	// URL electron is downloaded from, while bundling and at runtime, in "download" vendor mode. {os}, {arch} and
+	// {version} are replaced by their values. Default is the official electron URL
+	ElectronDownloadURL string `json:"electron_download_url"`
+
+	// Retries and timeouts of the vendor downloads
+	Download ConfigurationDownload `json:"download"`
+
+	// Number of vendor files downloaded concurrently before bundling. Default is 4
+	VendorDownloadConcurrency int `json:"vendor_download_concurrency"`
+
+	// Keep the files generated in the project (bind files, .syso, etc.) once the bundling is done
+	KeepGenerated bool `json:"keep_generated"`
+
+	// Bundle environments even if their inputs haven't changed since the last bundle
+	Force bool `json:"force"`
+}
+
+// ConfigurationVendorAssets represents the names vendor files are bound under
+type ConfigurationVendorAssets struct {
+	Astilectron string `json:"astilectron"` // Default is "astilectron.zip"
+	Electron    string `json:"electron"`    // Default is "electron.zip"
+	Prefix      string `json:"prefix"`      // Default is "vendor"
+}
+
+// ConfigurationResources represents a resources folder
+type ConfigurationResources struct {
+	// Path of the folder relative to the input path
+	Path string `json:"path"`
+	// Path, relative to the resources folder, under which files are bound. Default is the resources folder itself
+	Prefix string `json:"prefix"`
+}
+
+// ConfigurationBuild represents the go build configuration
+type ConfigurationBuild struct {
+	ASMFlags  string `json:"asmflags"`
+	BuildMode string `json:"buildmode"`
+	// Extra arguments added as is to go build
+	Flags   []string `json:"flags"`
+	GCFlags string   `json:"gcflags"`
+	Mod     string   `json:"mod"`
+	Race    *bool    `json:"race"`
+	// Omit the symbol table and debug information (-ldflags "-s -w")
+	Strip    *bool `json:"strip"`
+	TrimPath *bool `json:"trimpath"`
+	// Windows subsystem of the binary: "gui" (default), "console" to see logs in a console or "both" to add a
+	// console binary suffixed with "-debug" next to the gui one
+	WindowsSubsystem string `json:"windows_subsystem"`
+}
+
+// Windows subsystems
+const (
+	windowsSubsystemBoth    = "both"
+	windowsSubsystemConsole = "console"
+	windowsSubsystemGUI     = "gui"
+)
+
+// merge merges an environment build configuration into a global one.
+// Environment string and boolean values take precedence when set and flags are appended.
+func (c ConfigurationBuild) merge(e ConfigurationBuild) (o ConfigurationBuild) {
+	o = c
+	if len(e.ASMFlags) > 0 {
+		o.ASMFlags = e.ASMFlags
+	}
+	if len(e.BuildMode) > 0 {
+		o.BuildMode = e.BuildMode
+	}
+	o.Flags = append(append([]string{}, c.Flags...), e.Flags...)
+	if len(e.GCFlags) > 0 {
+		o.GCFlags = e.GCFlags
+	}
+	if len(e.Mod) > 0 {
+		o.Mod = e.Mod
+	}
+	if e.Race != nil {
+		o.Race = e.Race
+	}
+	if e.Strip != nil {
+		o.Strip = e.Strip
+	}
+	if e.TrimPath != nil {
+		o.TrimPath = e.TrimPath
+	}
+	if len(e.WindowsSubsystem) > 0 {
+		o.WindowsSubsystem = e.WindowsSubsystem
+	}
+	return
+}
+
+// args returns the go build arguments
+func (c ConfigurationBuild) args() (o []string) {
+	if len(c.ASMFlags) > 0 {
+		o = append(o, "-asmflags", c.ASMFlags)
+	}
+	if len(c.BuildMode) > 0 {
+		o = append(o, "-buildmode", c.BuildMode)
+	}
+	if len(c.GCFlags) > 0 {
+		o = append(o, "-gcflags", c.GCFlags)
+	}
+	if len(c.Mod) > 0 {
+		o = append(o, "-mod", c.Mod)
+	}
+	if isTrue(c.Race) {
+		o = append(o, "-race")
+	}
+	if isTrue(c.TrimPath) {
+		o = append(o, "-trimpath")
+	}
+	return append(o, c.Flags...)
+}
+
+// isTrue checks whether an optional boolean is set and true
+func isTrue(b *bool) bool {
+	return b != nil && *b
 }
 
 // ConfigurationEnvironment represents the bundle configuration environment
 type ConfigurationEnvironment struct {
 	Arch string `json:"arch"`
-	OS   string `json:"os"`
-	Tags string `json:"tags"`
+
+	// Go build options applied to this environment only
+	ConfigurationBuild
+
+	// Environment variables set when building, formatted as "KEY=value" (e.g. "CGO_ENABLED=1")
+	Env  []string `json:"env"`
+	OS   string   `json:"os"`
+	Tags string   `json:"tags"`
+}
+
+// name returns the environment name
+func (e ConfigurationEnvironment) name() string {
+	var n = e.OS + "-"
+	if len(e.Tags) > 0 {
+		n = n + strings.Replace(e.Tags, " ", "-", -1) + "-"
+	}
+	return n + e.Arch
 }
 
 // Bundler represents an object capable of bundling an Astilectron app
 type Bundler struct {
-	appName           string
-	cancel            context.CancelFunc
-	Client            *http.Client
-	ctx               context.Context
-	environments      []ConfigurationEnvironment
-	pathAstilectron   string
-	pathBuild         string
-	pathCache         string
-	pathIconDarwin    string
-	pathIconLinux     string
-	pathIconWindows   string
-	pathInput         string
-	pathGoBinary      string
-	pathOutput        string
-	pathResources     string
-	pathVendor        string
-	pathBindOutput    string
-	bindPackage       string
-	bindTags          string
-	environmentFilter string
+	appName                     string
+	bindBackend                 string
+	build                       ConfigurationBuild
+	buildEnvAll                 bool
+	buildEnvPassthrough         []string
+	cancel                      context.CancelFunc
+	Client                      *http.Client
+	ctx                         context.Context
+	electronDownloadURLTemplate string
+	OnDownloadProgress          func(p DownloadProgress) // Called periodically while downloading, possibly concurrently
+	electronPrune               ConfigurationElectronPrune
+	environments                []ConfigurationEnvironment
+	pathAstilectron             string
+	pathBuild                   string
+	pathCache                   string
+	pathIconDarwin              string
+	pathIconLinux               string
+	pathIconWindows             string
+	pathInput                   string
+	pathGoBinary                string
+	pathMain                    string
+	pathModule                  string
+	pathOutput                  string
+	vendorCodec                 string
+	vendorDownloadConcurrency   int
+	vendorMode                  string
+	vendorNames                 ConfigurationVendorAssets
+	pathResources               []resourcesPath
+	prefetches                  map[string]*vendorPrefetch
+	prefetchesMutex             *sync.Mutex
+	resourcesExclude            []string
+	resourcesInclude            []string
+	pathBindOutput              string
+	bindPackage                 string
+	bindTags                    string
+	compressionResources        int
+	compressionVendor           int
+	configurationHash           string
+	dev                         bool
+	download                    downloadOptions
+	environmentFilter           string
+	force                       bool
+	goVersion                   string
+	keepGenerated               bool
 }
 
 // absPath computes the absolute path
@@ -116,13 +325,37 @@ func absPath(configPath string, defaultPathFn func() (string, error)) (o string,
 	return
 }
 
+// modulePath returns the path of the directory containing the go.mod the path belongs to, if any
+func modulePath(path string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+			return path
+		}
+		var p = filepath.Dir(path)
+		if p == path {
+			return ""
+		}
+		path = p
+	}
+}
+
+// validateWindowsSubsystem validates a windows subsystem
+func validateWindowsSubsystem(s string) error {
+	switch s {
+	case "", windowsSubsystemBoth, windowsSubsystemConsole, windowsSubsystemGUI:
+		return nil
+	}
+	return fmt.Errorf("windows subsystem %s is invalid", s)
+}
+
 // New builds a new bundler based on a configuration
 func New(c *Configuration) (b *Bundler, err error) {
 	// Init
 	b = &Bundler{
-		appName:      c.AppName,
-		Client:       &http.Client{},
-		environments: c.Environments,
+		appName:         c.AppName,
+		build:           c.ConfigurationBuild,
+		environments:    c.Environments,
+		prefetchesMutex: &sync.Mutex{},
 	}
 
 	// Add context
@@ -135,6 +368,35 @@ func New(c *Configuration) (b *Bundler, err error) {
 			err = fmt.Errorf("OS %s is invalid", env.OS)
 			return
 		}
+
+		// Validate windows subsystem
+		if err = validateWindowsSubsystem(env.WindowsSubsystem); err != nil {
+			err = errors.Wrapf(err, "validating windows subsystem of environment %s/%s failed", env.OS, env.Arch)
+			return
+		}
+
+		// Validate env
+		for _, kv := range env.Env {
+			if !strings.Contains(kv, "=") {
+				err = fmt.Errorf("env %s of environment %s/%s is invalid, it should be formatted as KEY=value", kv, env.OS, env.Arch)
+				return
+			}
+		}
+	}
+
+	// Validate windows subsystem
+	if err = validateWindowsSubsystem(c.WindowsSubsystem); err != nil {
+		err = errors.Wrap(err, "validating windows subsystem failed")
+		return
+	}
+
+	// Build env passthrough
+	for _, k := range c.BuildEnvPassthrough {
+		if k == "*" {
+			b.buildEnvAll = true
+		} else {
+			b.buildEnvPassthrough = append(b.buildEnvPassthrough, k)
+		}
 	}
 
 	// Astilectron path
@@ -168,9 +430,40 @@ func New(c *Configuration) (b *Bundler, err error) {
 	}
 
 	// Paths that depends on the input path
-	b.pathBuild = strings.TrimPrefix(strings.TrimPrefix(b.pathInput, filepath.Join(os.Getenv("GOPATH"), "src")), string(os.PathSeparator))
-	b.pathResources = filepath.Join(b.pathInput, "resources")
-	b.pathVendor = filepath.Join(b.pathInput, "vendor")
+	b.pathMain = b.pathInput
+	if len(c.BuildPath) > 0 {
+		b.pathMain = filepath.Join(b.pathInput, c.BuildPath)
+	}
+	if os.Getenv("GO111MODULE") != "off" {
+		b.pathModule = modulePath(b.pathMain)
+	}
+	if len(b.pathModule) > 0 {
+		var r string
+		if r, err = filepath.Rel(b.pathModule, b.pathMain); err != nil {
+			err = errors.Wrapf(err, "filepath.Rel of %s and %s failed", b.pathModule, b.pathMain)
+			return
+		}
+		b.pathBuild = "./" + filepath.ToSlash(r)
+	} else {
+		b.pathBuild = strings.TrimPrefix(strings.TrimPrefix(b.pathMain, filepath.Join(os.Getenv("GOPATH"), "src")), string(os.PathSeparator))
+	}
+	if len(c.ResourcesPaths) == 0 {
+		b.pathResources = []resourcesPath{{optional: true, path: filepath.Join(b.pathInput, "resources")}}
+	}
+	for _, r := range c.ResourcesPaths {
+		if len(r.Path) == 0 {
+			err = errors.New("resources path is empty")
+			return
+		}
+		var prefix = path.Clean(filepath.ToSlash(r.Prefix))
+		if prefix == "." {
+			prefix = ""
+		} else if path.IsAbs(prefix) || prefix == ".." || strings.HasPrefix(prefix, "../") {
+			err = fmt.Errorf("resources prefix %s should be relative to the resources folder", r.Prefix)
+			return
+		}
+		b.pathResources = append(b.pathResources, resourcesPath{path: filepath.Join(b.pathInput, r.Path), prefix: prefix})
+	}
 
 	// Go binary path
 	b.pathGoBinary = "go"
@@ -193,6 +486,42 @@ func New(c *Configuration) (b *Bundler, err error) {
 		b.bindPackage = c.BindPackage
 	}
 
+	b.bindBackend = bindBackendBindata
+	switch c.BindBackend {
+	case "", bindBackendBindata:
+	case bindBackendEmbed:
+		b.bindBackend = c.BindBackend
+	default:
+		err = fmt.Errorf("bind backend %s is invalid", c.BindBackend)
+		return
+	}
+
+	// Resources patterns
+	for _, ps := range [][]string{c.ResourcesExclude, c.ResourcesInclude} {
+		if err = validatePatterns(ps); err != nil {
+			err = errors.Wrap(err, "validating resources patterns failed")
+			return
+		}
+	}
+	b.resourcesExclude = c.ResourcesExclude
+	b.resourcesInclude = c.ResourcesInclude
+
+	// Compression
+	if b.bindBackend == bindBackendBindata && c.BindCompression.Resources != nil {
+		err = errors.New("resources compression level is only supported by the embed backend since go-bindata always uses its default level")
+		return
+	}
+	if b.compressionResources, err = compressionLevel(c.BindCompression.Resources, gzip.DefaultCompression); err != nil {
+		err = errors.Wrap(err, "computing resources compression level failed")
+		return
+	}
+	if b.compressionVendor, err = compressionLevel(c.BindCompression.Vendor, gzip.NoCompression); err != nil {
+		err = errors.Wrap(err, "computing vendor compression level failed")
+		return
+	}
+
+	b.dev = c.BindDev
+
 	b.bindTags = ""
 	if len(c.BindTags) > 0 {
 		b.bindTags = c.BindTags
@@ -202,9 +531,117 @@ func New(c *Configuration) (b *Bundler, err error) {
 	if len(c.EnvironmentFilter) > 0 {
 		b.environmentFilter = c.EnvironmentFilter
 	}
+
+	b.keepGenerated = c.KeepGenerated
+
+	b.electronDownloadURLTemplate = c.ElectronDownloadURL
+	b.vendorMode = vendorModeBind
+	switch c.VendorMode {
+	case "", vendorModeBind:
+	case vendorModeDownload, vendorModePayload:
+		b.vendorMode = c.VendorMode
+	default:
+		err = fmt.Errorf("vendor mode %s is invalid", c.VendorMode)
+		return
+	}
+
+	// Vendor downloads
+	if b.download, err = newDownloadOptions(c.Download); err != nil {
+		err = errors.Wrap(err, "parsing download configuration failed")
+		return
+	}
+	if b.Client, err = newDownloadClient(c.Download); err != nil {
+		err = errors.Wrap(err, "building download client failed")
+		return
+	}
+	if b.vendorDownloadConcurrency, err = validateVendorDownloadConcurrency(c.VendorDownloadConcurrency); err != nil {
+		err = errors.Wrap(err, "validating vendor download concurrency failed")
+		return
+	}
+
+	// Vendor codec
+	switch c.VendorCodec {
+	case "", vendorCodecXZ, vendorCodecZstd:
+		b.vendorCodec = c.VendorCodec
+	default:
+		err = fmt.Errorf("vendor codec %s is invalid", c.VendorCodec)
+		return
+	}
+
+	// Electron pruning
+	if err = validateElectronPrune(c.ElectronPrune); err != nil {
+		err = errors.Wrap(err, "validating electron pruning failed")
+		return
+	}
+	if b.vendorMode != vendorModeDownload {
+		b.electronPrune = c.ElectronPrune
+	}
+
+	// Vendor names
+	b.vendorNames = ConfigurationVendorAssets{Astilectron: zipNameAstilectron, Electron: zipNameElectron, Prefix: vendorPrefix}
+	for _, n := range []string{c.VendorAssets.Astilectron, c.VendorAssets.Electron, c.VendorAssets.Prefix} {
+		if err = validateVendorName(n); err != nil {
+			err = errors.Wrap(err, "validating vendor assets failed")
+			return
+		}
+	}
+	if len(c.VendorAssets.Astilectron) > 0 {
+		b.vendorNames.Astilectron = path.Clean(c.VendorAssets.Astilectron)
+	}
+	if len(c.VendorAssets.Electron) > 0 {
+		b.vendorNames.Electron = path.Clean(c.VendorAssets.Electron)
+	}
+	if p := strings.TrimRight(c.VendorAssets.Prefix, "/"); len(p) > 0 {
+		b.vendorNames.Prefix = path.Clean(p)
+	}
+	if err = validateVendorNames(b.vendorNames, b.vendorMode, b.vendorCodec); err != nil {
+		err = errors.Wrap(err, "validating vendor assets failed")
+		return
+	}
+
+	// Fingerprint
+	b.force = c.Force
+	if b.configurationHash, err = configurationHash(*c); err != nil {
+		err = errors.Wrap(err, "hashing configuration failed")
+		return
+	}
 	return
 }
 
+// validateVendorName checks that a vendor name is relative and stays within the folder it's staged in
+func validateVendorName(n string) error {
+	if strings.HasPrefix(n, "/") || strings.Contains(n, "\\") || len(filepath.VolumeName(n)) > 0 {
+		return fmt.Errorf("vendor asset name %s must be a relative slash separated path", n)
+	}
+	for _, s := range strings.Split(n, "/") {
+		if s == ".." {
+			return fmt.Errorf("vendor asset name %s can't contain .. elements", n)
+		}
+	}
+	return nil
+}
+
+// validateVendorNames checks that the vendor files can be bound under their names
+func validateVendorNames(n ConfigurationVendorAssets, vendorMode, vendorCodec string) error {
+	var m = make(map[string]bool)
+	for _, v := range []string{n.Astilectron, n.Electron, metadataName} {
+		if m[v] {
+			return fmt.Errorf("vendor asset name %s is used twice", v)
+		} else if p := path.Join(n.Prefix, v); p == "resources" || strings.HasPrefix(p, "resources/") {
+			return fmt.Errorf("vendor asset name %s collides with the resources", p)
+		}
+		m[v] = true
+	}
+	if len(vendorCodec) > 0 && (!strings.HasSuffix(n.Astilectron, ".zip") || !strings.HasSuffix(n.Electron, ".zip")) {
+		return fmt.Errorf("vendor asset names must end with .zip when vendor codec is %s", vendorCodec)
+	} else if vendorMode == vendorModeDownload && !strings.HasSuffix(n.Electron, ".zip") {
+		return fmt.Errorf("electron vendor asset name %s must end with .zip in download vendor mode", n.Electron)
+	} else if vendorMode == vendorModeDownload && downloadName(n.Electron) == n.Astilectron {
+		return fmt.Errorf("vendor asset name %s is used twice", n.Astilectron)
+	}
+	return nil
+}
+
 // HandleSignals handles signals
 func (b *Bundler) HandleSignals() {
 	ch := make(chan os.Signal, 1)
@@ -243,16 +680,22 @@ func (b *Bundler) Bundle() (err error) {
 		return
 	}
 
-	// Loop through environments
+	// Warn about the legacy windows .syso
+	b.warnLegacyWindowsSyso()
+
+	// Read manifest
+	var m manifest
+	if m, err = b.readManifest(); err != nil {
+		err = errors.Wrap(err, "reading manifest failed")
+		return
+	}
+
+	// Filter environments
+	var es []ConfigurationEnvironment
 	for _, e := range b.environments {
-		eName := e.OS + "-"
-		if e.Tags != "" {
-			eName = eName + strings.Replace(e.Tags, " ", "-", -1) + "-"
-		}
-		eName = eName + e.Arch
 		if b.environmentFilter != "" {
 			var m bool
-			m, err = regexp.MatchString(b.environmentFilter, eName)
+			m, err = regexp.MatchString(b.environmentFilter, e.name())
 			if err != nil {
 				err = errors.Wrap(err, "environment matching failed")
 				return
@@ -261,12 +704,58 @@ func (b *Bundler) Bundle() (err error) {
 				continue
 			}
 		}
+		es = append(es, e)
+	}
+
+	// Prefetch vendor files so that they're downloaded while the first environment is compiled
+	var ctx, cancel = context.WithCancel(b.ctx)
+	var wg = b.prefetchVendor(ctx, es)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// Loop through environments
+	for _, e := range es {
+		eName := e.name()
+
+		// Fingerprint
+		var f string
+		if f, err = b.fingerprint(e); err != nil {
+			err = errors.Wrapf(err, "fingerprinting environment %s/%s failed", e.OS, e.Arch)
+			return
+		}
+
+		// Environment is up to date
+		if !b.force && m[eName] == f {
+			if _, errStat := os.Stat(filepath.Join(b.pathOutput, eName)); errStat == nil {
+				astilog.Debugf("Environment %s/%s is up to date, skipping", e.OS, e.Arch)
+				continue
+			}
+		}
+
+		// Invalidate previous fingerprint
+		delete(m, eName)
+		if err = b.writeManifest(m); err != nil {
+			err = errors.Wrap(err, "writing manifest failed")
+			return
+		}
 
 		astilog.Debugf("Bundling for environment %s/%s", e.OS, e.Arch)
 		if err = b.bundle(e); err != nil {
 			err = errors.Wrapf(err, "bundling for environment %s/%s failed", e.OS, e.Arch)
 			return
 		}
+
+		// Fingerprint again since the vendor zips may have been downloaded in the meantime
+		if m[eName], err = b.fingerprint(e); err != nil {
+			err = errors.Wrapf(err, "fingerprinting environment %s/%s failed", e.OS, e.Arch)
+			return
+		}
+		if err = b.writeManifest(m); err != nil {
+			err = errors.Wrap(err, "writing manifest failed")
+			return
+		}
 	}
 	return
 }
@@ -281,14 +770,30 @@ func (b *Bundler) reset() (err error) {
 			return
 		}
 	}
+
+	// Retrieve go version
+	var cmd = exec.Command(b.pathGoBinary, "version")
+	cmd.Dir = b.pathModule
+	var o []byte
+	astilog.Debugf("Executing %s", strings.Join(cmd.Args, " "))
+	if o, err = cmd.CombinedOutput(); err != nil {
+		err = errors.Wrapf(err, "retrieving go version failed: %s", o)
+		return
+	}
+	b.goVersion = strings.TrimSpace(string(o))
 	return
 }
 
-// provisionVendorZip provisions a vendor zip file
-func (b *Bundler) provisionVendorZip(pathDownload, pathCache, pathVendor string) (err error) {
+// provisionVendorZip provisions a vendor zip file in the cache
+func (b *Bundler) provisionVendorZip(pathDownload, pathCache string) (err error) {
+	// Wait for prefetch
+	if err = b.waitPrefetch(pathCache); err != nil {
+		return
+	}
+
 	// Download source
 	if _, errStat := os.Stat(pathCache); os.IsNotExist(errStat) {
-		if err = astilectron.Download(b.ctx, b.Client, pathDownload, pathCache); err != nil {
+		if err = b.downloadVendor(b.ctx, pathDownload, pathCache); err != nil {
 			err = errors.Wrapf(err, "downloading %s into %s failed", pathDownload, pathCache)
 			return
 		}
@@ -300,24 +805,33 @@ func (b *Bundler) provisionVendorZip(pathDownload, pathCache, pathVendor string)
 	if b.ctx.Err() != nil {
 		return b.ctx.Err()
 	}
+	return
+}
 
-	// Copy
-	astilog.Debugf("Copying %s to %s", pathCache, pathVendor)
-	if err = astios.Copy(b.ctx, pathCache, pathVendor); err != nil {
-		err = errors.Wrapf(err, "copying %s to %s failed", pathCache, pathVendor)
-		return
-	}
+// pathCacheAstilectron returns the path of the cached astilectron vendor zip file
+func (b *Bundler) pathCacheAstilectron() string {
+	return filepath.Join(b.pathCache, fmt.Sprintf("astilectron-%s.zip", astilectron.VersionAstilectron))
+}
 
-	// Check context error
-	if b.ctx.Err() != nil {
-		return b.ctx.Err()
+// pathCacheElectron returns the path of the cached electron vendor zip file
+func (b *Bundler) pathCacheElectron(oS, arch string) string {
+	var n = fmt.Sprintf("electron-%s-%s-%s", oS, arch, astilectron.VersionElectron)
+	if src := b.electronSrc(oS, arch); src != astilectron.ElectronDownloadSrc(oS, arch) {
+		// Mirrors may serve a different artifact
+		var h = sha256.Sum256([]byte(src))
+		n += "-" + hex.EncodeToString(h[:8])
 	}
-	return
+	return filepath.Join(b.pathCache, n+".zip")
+}
+
+// pathCacheElectronDownload returns the path of the cached electron download information
+func (b *Bundler) pathCacheElectronDownload(oS, arch string) string {
+	return strings.TrimSuffix(b.pathCacheElectron(oS, arch), ".zip") + ".json"
 }
 
 // provisionVendorAstilectron provisions the astilectron vendor zip file
 func (b *Bundler) provisionVendorAstilectron() (err error) {
-	var p = filepath.Join(b.pathCache, fmt.Sprintf("astilectron-%s.zip", astilectron.VersionAstilectron))
+	var p = b.pathCacheAstilectron()
 	if len(b.pathAstilectron) > 0 {
 		// Zip
 		astilog.Debugf("Zipping %s into %s", b.pathAstilectron, p)
@@ -331,30 +845,26 @@ func (b *Bundler) provisionVendorAstilectron() (err error) {
 			return b.ctx.Err()
 		}
 	}
-	return b.provisionVendorZip(astilectron.AstilectronDownloadSrc(), p, filepath.Join(b.pathVendor, zipNameAstilectron))
+	return b.provisionVendorZip(astilectron.AstilectronDownloadSrc(), p)
 }
 
 // provisionVendorElectron provisions the electron vendor zip file
-func (b *Bundler) provisionVendorElectron(oS, arch string) error {
-	return b.provisionVendorZip(astilectron.ElectronDownloadSrc(oS, arch), filepath.Join(b.pathCache, fmt.Sprintf("electron-%s-%s-%s.zip", oS, arch, astilectron.VersionElectron)), filepath.Join(b.pathVendor, zipNameElectron))
-}
-
-// provisionVendor provisions the vendor folder
-func (b *Bundler) provisionVendor(oS, arch string) (err error) {
-	// Remove previous vendor folder
-	astilog.Debugf("Removing %s", b.pathVendor)
-	if err = os.RemoveAll(b.pathVendor); err != nil {
-		err = errors.Wrapf(err, "removing %s failed", b.pathVendor)
+func (b *Bundler) provisionVendorElectron(oS, arch string) (err error) {
+	// Download
+	if err = b.provisionVendorZip(b.electronSrc(oS, arch), b.pathCacheElectron(oS, arch)); err != nil {
 		return
 	}
 
-	// Create the vendor folder
-	astilog.Debugf("Creating %s", b.pathVendor)
-	if err = os.MkdirAll(b.pathVendor, 0777); err != nil {
-		err = errors.Wrapf(err, "mkdirall %s failed", b.pathVendor)
+	// Prune
+	if err = b.provisionVendorElectronPruned(oS, arch); err != nil {
+		err = errors.Wrap(err, "pruning electron failed")
 		return
 	}
+	return
+}
 
+// provisionVendor provisions the vendor zip files in the cache
+func (b *Bundler) provisionVendor(oS, arch string) (err error) {
 	// Provision astilectron
 	if err = b.provisionVendorAstilectron(); err != nil {
 		err = errors.Wrap(err, "provisioning astilectron vendor failed")
@@ -366,41 +876,400 @@ func (b *Bundler) provisionVendor(oS, arch string) (err error) {
 		err = errors.Wrapf(err, "provisioning electron vendor for OS %s and arch %s failed", oS, arch)
 		return
 	}
+
+	// Repack
+	var ps = []string{b.pathCacheAstilectron()}
+	if b.vendorMode != vendorModeDownload {
+		ps = append(ps, b.pathElectron(oS, arch))
+	}
+	for _, p := range ps {
+		if err = b.provisionVendorRepacked(p); err != nil {
+			err = errors.Wrapf(err, "repacking %s failed", p)
+			return
+		}
+	}
+
+	// Provision electron download information
+	if b.vendorMode == vendorModeDownload {
+		if err = b.provisionVendorElectronDownload(oS, arch); err != nil {
+			err = errors.Wrapf(err, "provisioning electron download information for OS %s and arch %s failed", oS, arch)
+			return
+		}
+	}
+	return
+}
+
+// provisionVendorElectronDownload writes the URL and SHA-256 of the electron zip downloaded at runtime in download mode
+func (b *Bundler) provisionVendorElectronDownload(oS, arch string) (err error) {
+	// Hash
+	var d = vendorDownload{URL: b.electronDownloadURL(oS, arch)}
+	if d.SHA256, err = sha256File(b.pathCacheElectron(oS, arch)); err != nil {
+		err = errors.Wrapf(err, "hashing %s failed", b.pathCacheElectron(oS, arch))
+		return
+	}
+
+	// Write
+	var c []byte
+	if c, err = json.Marshal(d); err != nil {
+		err = errors.Wrap(err, "marshaling download information failed")
+		return
+	}
+	var p = b.pathCacheElectronDownload(oS, arch)
+	astilog.Debugf("Writing download information of %s to %s", d.URL, p)
+	if err = ioutil.WriteFile(p, c, 0666); err != nil {
+		err = errors.Wrapf(err, "writing %s failed", p)
+		return
+	}
+	return
+}
+
+// electronSrc returns the URL electron is downloaded from while bundling. In download mode, it's the URL electron
+// is downloaded from at runtime so that the recorded SHA-256 matches the artifact the app will get.
+func (b *Bundler) electronSrc(oS, arch string) string {
+	if b.vendorMode == vendorModeDownload {
+		return b.electronDownloadURL(oS, arch)
+	}
+	return astilectron.ElectronDownloadSrc(oS, arch)
+}
+
+// electronDownloadURL returns the URL electron is downloaded from at runtime in download mode
+func (b *Bundler) electronDownloadURL(oS, arch string) string {
+	if len(b.electronDownloadURLTemplate) == 0 {
+		return astilectron.ElectronDownloadSrc(oS, arch)
+	}
+	return strings.NewReplacer("{arch}", arch, "{os}", oS, "{version}", astilectron.VersionElectron).Replace(b.electronDownloadURLTemplate)
+}
+
+// sha256File returns the hex encoded SHA-256 of a file
+func sha256File(path string) (o string, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", path)
+		return
+	}
+	defer f.Close()
+	var h = sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		err = errors.Wrapf(err, "hashing %s failed", path)
+		return
+	}
+	o = hex.EncodeToString(h.Sum(nil))
 	return
 }
 
 // BindData binds the data
-func (b *Bundler) BindData(os, arch, tags string) (err error) {
+func (b *Bundler) BindData(os, arch, tags string) error {
+	return b.bind(os, arch, tags, b.dev, true)
+}
+
+// bind binds the data, reading resources from disk at runtime in dev mode. Vendor zips are only bound if vendor
+// is true.
+func (b *Bundler) bind(os, arch, tags string, dev, vendor bool) (err error) {
+	// Remove stale bind files unless generated files are kept, in which case bind files of other environments
+	// must be kept too
+	if !b.keepGenerated {
+		if err = b.cleanBindData(os, arch); err != nil {
+			err = errors.Wrap(err, "cleaning bind files failed")
+			return
+		}
+	}
+
 	// Provision the vendor
 	if err = b.provisionVendor(os, arch); err != nil {
 		err = errors.Wrap(err, "provisioning the vendor failed")
 		return
 	}
 
+	// Build constraint
+	var bc string
+	if bc, err = buildConstraint(os, arch, b.bindTags); err != nil {
+		err = errors.Wrap(err, "building constraint failed")
+		return
+	}
+
+	// Build assets
+	var as []asset
+	if as, err = b.resourceAssets(os, arch); err != nil {
+		err = errors.Wrap(err, "building resource assets failed")
+		return
+	}
+	var vas = b.vendorAssets(os, arch)
+
+	// Digest assets, including vendor zips even if they're not bound so that they can be verified at runtime
+	var ds []assetDigest
+	if ds, err = digestAssets(append(append([]asset{}, as...), vas...)); err != nil {
+		err = errors.Wrap(err, "digesting assets failed")
+		return
+	}
+
+	// Write metadata
+	var ma asset
+	if ma, err = b.writeMetadata(os, arch, tags, len(as), ds); err != nil {
+		err = errors.Wrap(err, "writing metadata failed")
+		return
+	}
+
+	// Dev mode
+	if dev {
+		if err = b.removeBindDataVendor(os, arch); err != nil {
+			err = errors.Wrap(err, "removing bindata vendor file failed")
+			return
+		}
+		err = b.bindDev(os, arch, bc, ma)
+		return
+	}
+
+	// Add vendor
+	if vendor {
+		as = append(as, vas...)
+	}
+	as = append(as, ma)
+
+	// Bind data
+	switch b.bindBackend {
+	case bindBackendEmbed:
+		if err = b.removeBindDataVendor(os, arch); err != nil {
+			err = errors.Wrap(err, "removing bindata vendor file failed")
+			return
+		}
+		err = b.bindEmbed(os, arch, bc, as, ds)
+	default:
+		err = b.bindData(os, arch, bc, as, ds)
+	}
+	return
+}
+
+// regexpBindDataStale matches the files and directories generated by BindData for any os and arch
+var regexpBindDataStale = regexp.MustCompile(`^bind_(darwin|linux|windows)(_[a-z0-9]+)?(_vendor\.go|\.go)?$`)
+
+// pathBindData returns the path of the file generated by BindData
+func (b *Bundler) pathBindData(oS, arch string) string {
+	return filepath.Join(b.pathBindOutput, fmt.Sprintf("bind_%s_%s.go", oS, arch))
+}
+
+// cleanBindData removes the files and directories previously generated by BindData for other environments
+// as well as the legacy bind_<os>.go files
+func (b *Bundler) cleanBindData(oS, arch string) (err error) {
+	// Read dir
+	var fs []os.FileInfo
+	if fs, err = ioutil.ReadDir(b.pathBindOutput); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = errors.Wrapf(err, "reading dir %s failed", b.pathBindOutput)
+		return
+	}
+
+	// Loop through files
+	for _, f := range fs {
+		// Not stale
+		var p = filepath.Join(b.pathBindOutput, f.Name())
+		if !regexpBindDataStale.MatchString(f.Name()) || p == b.pathBindData(oS, arch) || p == b.pathBindDataVendor(oS, arch) || p == b.pathBindEmbed(oS, arch) {
+			continue
+		}
+
+		// Make sure the file or directory has been generated
+		var g bool
+		if f.IsDir() {
+			g, err = isGeneratedDir(p)
+		} else {
+			g, err = isGenerated(p)
+		}
+		if err != nil {
+			err = errors.Wrapf(err, "checking whether %s is generated failed", p)
+			return
+		} else if !g {
+			astilog.Debugf("%s has not been generated by the bundler, skipping", p)
+			continue
+		}
+
+		// Remove
+		if err = removeAll(p); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// isGenerated checks whether a go file has been generated
+func isGenerated(path string) (o bool, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", path)
+		return
+	}
+	defer f.Close()
+	var buf = make([]byte, 512)
+	var n int
+	if n, err = io.ReadFull(f, buf); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		err = errors.Wrapf(err, "reading %s failed", path)
+		return
+	}
+	err = nil
+	o = bytes.Contains(buf[:n], []byte("// Code generated by"))
+	return
+}
+
+// buildConstraint returns the //go:build expression of a bind file.
+// Tags can either use the //go:build or the legacy // +build syntax.
+func buildConstraint(oS, arch, tags string) (o string, err error) {
+	var e constraint.Expr = &constraint.AndExpr{X: &constraint.TagExpr{Tag: oS}, Y: &constraint.TagExpr{Tag: arch}}
+	if len(tags) > 0 {
+		var t constraint.Expr
+		if t, err = constraint.Parse("//go:build " + tags); err != nil {
+			if t, err = constraint.Parse("// +build " + tags); err != nil {
+				err = errors.Wrapf(err, "parsing tags %s failed", tags)
+				return
+			}
+		}
+		e = &constraint.AndExpr{X: e, Y: t}
+	}
+	o = e.String()
+	return
+}
+
+// bindData binds the resources using go-bindata, and the vendor files next to them using go:embed
+func (b *Bundler) bindData(oS, arch, bc string, as []asset, ds []assetDigest) (err error) {
+	// Split assets
+	var ras, vas []asset
+	for _, a := range as {
+		if a.vendor {
+			vas = append(vas, a)
+		} else {
+			ras = append(ras, a)
+		}
+	}
+
+	// Create staging dir
+	var d string
+	if d, err = ioutil.TempDir("", "astibundler"); err != nil {
+		err = errors.Wrap(err, "creating staging dir failed")
+		return
+	}
+	defer os.RemoveAll(d)
+
+	// Stage assets
+	astilog.Debugf("Staging assets into %s", d)
+	var r bindReport
+	if r, _, err = b.stageAssets(ras, d, false); err != nil {
+		err = errors.Wrapf(err, "staging assets into %s failed", d)
+		return
+	}
+
 	// Build bindata config
 	var c = bindata.NewConfig()
-	c.Input = []bindata.InputConfig{
-		{Path: filepath.Join(b.pathInput, "resources"), Recursive: true},
-		{Path: filepath.Join(b.pathInput, "vendor"), Recursive: true},
-	}
-	c.Output = filepath.Join(b.pathBindOutput, fmt.Sprintf("bind_%s.go", os))
-	c.Prefix = b.pathInput
+	c.Input = []bindata.InputConfig{{Path: d, Recursive: true}}
+	c.Output = b.pathBindData(oS, arch)
+	c.Prefix = d
 	c.Package = b.bindPackage
-	c.Tags = os
-	if len(b.bindTags) > 0 {
-		c.Tags = c.Tags + "\n// +build " + b.bindTags + ""
-	}
 
 	// Bind data
 	astilog.Debugf("Generating %s", c.Output)
-	err = bindata.Translate(c)
+	var n = time.Now()
+	if err = bindata.Translate(c); err != nil {
+		err = errors.Wrapf(err, "generating %s failed", c.Output)
+		return
+	}
+	for _, s := range r {
+		s.compression = "go-bindata gzip"
+		s.duration += time.Since(n)
+		s.sizeStored = 0
+	}
+
+	// Bind vendor
+	var vr bindReport
+	if vr, err = b.bindDataVendor(oS, arch, bc, vas); err != nil {
+		err = errors.Wrap(err, "binding vendor failed")
+		return
+	}
+	for g, s := range vr {
+		r[g] = s
+	}
+
+	// Report
+	r.log()
+	astilog.Infof("Generated %s in %s", c.Output, time.Since(n))
+
+	// Append digests
+	if err = appendDigests(c.Output, ds); err != nil {
+		err = errors.Wrapf(err, "appending digests to %s failed", c.Output)
+		return
+	}
+
+	// go-bindata only supports the legacy // +build syntax, therefore we add the build constraint ourselves
+	if err = prependFile(c.Output, []byte("//go:build "+bc+"\n\n")); err != nil {
+		err = errors.Wrapf(err, "adding build constraint to %s failed", c.Output)
+		return
+	}
 	return
 }
 
+// prependFile adds content at the beginning of a file
+func prependFile(path string, content []byte) (err error) {
+	// Open source
+	var src *os.File
+	if src, err = os.Open(path); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", path)
+		return
+	}
+	defer src.Close()
+
+	// Create destination
+	var p = path + ".tmp"
+	var dst *os.File
+	if dst, err = os.Create(p); err != nil {
+		err = errors.Wrapf(err, "creating %s failed", p)
+		return
+	}
+	defer os.Remove(p)
+	defer dst.Close()
+
+	// Write
+	if _, err = dst.Write(content); err != nil {
+		err = errors.Wrapf(err, "writing to %s failed", p)
+		return
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		err = errors.Wrapf(err, "copying %s to %s failed", path, p)
+		return
+	}
+	if err = dst.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s failed", p)
+		return
+	}
+
+	// Rename
+	if err = os.Rename(p, path); err != nil {
+		err = errors.Wrapf(err, "renaming %s into %s failed", p, path)
+		return
+	}
+	return
+}
+
+// pathWindowsSyso returns the path of the windows .syso. Its name makes sure it's only linked for its arch.
+func (b *Bundler) pathWindowsSyso(arch string) string {
+	return filepath.Join(b.pathMain, fmt.Sprintf("rsrc_windows_%s.syso", arch))
+}
+
+// warnLegacyWindowsSyso warns about the windows.syso generated by previous versions of the bundler. Its name has
+// no OS and arch, therefore it's linked in every build. It's not removed since it may have been added by the user.
+func (b *Bundler) warnLegacyWindowsSyso() {
+	var ps = []string{filepath.Join(b.pathInput, "windows.syso")}
+	if b.pathMain != b.pathInput {
+		ps = append(ps, filepath.Join(b.pathMain, "windows.syso"))
+	}
+	for _, p := range ps {
+		if _, err := os.Stat(p); err == nil {
+			astilog.Warnf("%s has probably been generated by a previous version of the bundler and is linked when building for every OS and arch, remove it unless you've added it yourself", p)
+		}
+	}
+}
+
 // addWindowsSyso adds the proper windows .syso if needed
 func (b *Bundler) addWindowsSyso(arch string) (err error) {
 	if len(b.pathIconWindows) > 0 {
-		var p = filepath.Join(b.pathInput, "windows.syso")
+		var p = b.pathWindowsSyso(arch)
 		astilog.Debugf("Running rsrc for icon %s into %s", b.pathIconWindows, p)
 		if err = rsrc.Embed(p, arch, "", b.pathIconWindows); err != nil {
 			err = errors.Wrapf(err, "running rsrc for icon %s into %s failed", b.pathIconWindows, p)
@@ -410,6 +1279,25 @@ func (b *Bundler) addWindowsSyso(arch string) (err error) {
 	return
 }
 
+// removeGenerated removes the files generated in the project while bundling an environment
+func (b *Bundler) removeGenerated(oS, arch string) (err error) {
+	for _, p := range []string{b.pathBindData(oS, arch), b.pathBindDataVendor(oS, arch), b.pathWindowsSyso(arch)} {
+		if err = removeAll(p); err != nil {
+			return
+		}
+	}
+	var g bool
+	if g, err = isGeneratedDir(b.pathBindEmbed(oS, arch)); err != nil {
+		err = errors.Wrapf(err, "checking whether %s is generated failed", b.pathBindEmbed(oS, arch))
+		return
+	} else if g {
+		if err = removeAll(b.pathBindEmbed(oS, arch)); err != nil {
+			return
+		}
+	}
+	return
+}
+
 // ldflags represents ldflags
 type ldflags map[string][]string
 
@@ -418,21 +1306,138 @@ func (l ldflags) string() string {
 	var o []string
 	for k, ss := range l {
 		for _, s := range ss {
+			if len(s) == 0 {
+				o = append(o, "-"+k)
+				continue
+			}
 			o = append(o, fmt.Sprintf(`-%s %s`, k, s))
 		}
 	}
 	return strings.Join(o, " ")
 }
 
+// buildEnvKeys are the environment variables passed to go build by default
+var buildEnvKeys = []string{
+	"APPDATA",
+	"CC",
+	"CGO_CFLAGS",
+	"CGO_CPPFLAGS",
+	"CGO_CXXFLAGS",
+	"CGO_ENABLED",
+	"CGO_LDFLAGS",
+	"CXX",
+	"GO111MODULE",
+	"GO386",
+	"GOAMD64",
+	"GOARM",
+	"GOCACHE",
+	"GOEXPERIMENT",
+	"GOFLAGS",
+	"GOINSECURE",
+	"GOMODCACHE",
+	"GONOPROXY",
+	"GONOSUMDB",
+	"GOPATH",
+	"GOPRIVATE",
+	"GOPROXY",
+	"GOROOT",
+	"GOSUMDB",
+	"GOTMPDIR",
+	"GOTOOLCHAIN",
+	"GOWORK",
+	"HOME",
+	"HTTP_PROXY",
+	"http_proxy",
+	"HTTPS_PROXY",
+	"https_proxy",
+	"LOCALAPPDATA",
+	"NO_PROXY",
+	"no_proxy",
+	"PATH",
+	"PKG_CONFIG_PATH",
+	"SYSTEMROOT",
+	"TEMP",
+	"TMP",
+	"TMPDIR",
+	"USERPROFILE",
+	"XDG_CACHE_HOME",
+}
+
+// setEnv sets a "key=value" entry in an environment, replacing the previous value of the key if any.
+// Keys are case insensitive on windows.
+func setEnv(env []string, kv string) []string {
+	var k = strings.SplitN(kv, "=", 2)[0]
+	for i, v := range env {
+		if ek := strings.SplitN(v, "=", 2)[0]; ek == k || (runtime.GOOS == "windows" && strings.EqualFold(ek, k)) {
+			env[i] = kv
+			return env
+		}
+	}
+	return append(env, kv)
+}
+
+// buildEnv builds the go build environment of an environment
+func (b *Bundler) buildEnv(e ConfigurationEnvironment) (o []string) {
+	// Passthrough
+	if b.buildEnvAll {
+		o = os.Environ()
+	} else {
+		for _, k := range append(append([]string{}, buildEnvKeys...), b.buildEnvPassthrough...) {
+			if v, ok := os.LookupEnv(k); ok {
+				o = setEnv(o, k+"="+v)
+			}
+		}
+	}
+
+	// Environment specific
+	for _, kv := range e.Env {
+		o = setEnv(o, kv)
+	}
+
+	// Target
+	o = setEnv(o, "GOARCH="+e.Arch)
+	o = setEnv(o, "GOOS="+e.OS)
+	return
+}
+
+// goBuild builds the binary of an environment
+func (b *Bundler) goBuild(e ConfigurationEnvironment, c ConfigurationBuild, binaryPath string, windowsGUI bool) (err error) {
+	// Build ldflags
+	var l = ldflags{
+		"X": []string{
+			//`"` + b.bindPackage + `.AppName=` + b.appName + `"`,
+			`"` + b.bindPackage + `.BuiltAt=` + time.Now().String() + `"`,
+		},
+	}
+	if windowsGUI {
+		l["H"] = []string{"windowsgui"}
+	}
+	if isTrue(c.Strip) {
+		l["s"] = []string{""}
+		l["w"] = []string{""}
+	}
+
+	// Build cmd
+	astilog.Debugf("Building for os %s and arch %s with tags %s", e.OS, e.Arch, e.Tags)
+	var args = append([]string{"build", "-ldflags", l.string(), "-o", binaryPath, "-tags", e.Tags}, c.args()...)
+	var cmd = exec.Command(b.pathGoBinary, append(args, b.pathBuild)...)
+	cmd.Dir = b.pathModule
+	cmd.Env = b.buildEnv(e)
+
+	// Exec
+	var o []byte
+	astilog.Debugf("Executing %s", strings.Join(cmd.Args, " "))
+	if o, err = cmd.CombinedOutput(); err != nil {
+		err = errors.Wrapf(err, "building failed: %s", o)
+		return
+	}
+	return
+}
+
 // bundle bundles an os
 func (b *Bundler) bundle(e ConfigurationEnvironment) (err error) {
 	// Remove previous environment folder
-	var environmentPath = filepath.Join(b.pathOutput, e.OS)
-	if len(e.Tags) > 0 {
-		tags := strings.Replace(e.Tags, " ", "-", -1)
-		environmentPath = environmentPath + "-" + tags
-	}
-	environmentPath = environmentPath + "-" + e.Arch
+	var environmentPath = filepath.Join(b.pathOutput, e.name())
 	astilog.Debugf("Removing %s", environmentPath)
 	if err = os.RemoveAll(environmentPath); err != nil {
 		err = errors.Wrapf(err, "removing %s failed", environmentPath)
@@ -446,9 +1451,18 @@ func (b *Bundler) bundle(e ConfigurationEnvironment) (err error) {
 		return
 	}
 
+	// Remove generated files once done
+	if !b.keepGenerated {
+		defer func() {
+			if errClean := b.removeGenerated(e.OS, e.Arch); errClean != nil && err == nil {
+				err = errors.Wrap(errClean, "removing generated files failed")
+			}
+		}()
+	}
+
 	// Bind data
 	astilog.Debug("Binding data")
-	if err = b.BindData(e.OS, e.Arch, e.Tags); err != nil {
+	if err = b.bind(e.OS, e.Arch, e.Tags, false, b.vendorMode != vendorModePayload); err != nil {
 		err = errors.Wrap(err, "binding data failed")
 		return
 	}
@@ -461,34 +1475,35 @@ func (b *Bundler) bundle(e ConfigurationEnvironment) (err error) {
 		}
 	}
 
-	// Build ldflags
-	var l = ldflags{
-		"X": []string{
-			//`"` + b.bindPackage + `.AppName=` + b.appName + `"`,
-			`"` + b.bindPackage + `.BuiltAt=` + time.Now().String() + `"`,
-		},
-	}
-	if e.OS == "windows" {
-		l["H"] = []string{"windowsgui"}
+	// Build
+	var c = b.build.merge(e.ConfigurationBuild)
+	var binaryPath = filepath.Join(environmentPath, "binary")
+	if err = b.goBuild(e, c, binaryPath, e.OS == "windows" && c.WindowsSubsystem != windowsSubsystemConsole); err != nil {
+		err = errors.Wrap(err, "building binary failed")
+		return
 	}
 
-	// Build cmd
-	astilog.Debugf("Building for os %s and arch %s with tags %s", e.OS, e.Arch, e.Tags)
-	var binaryPath = filepath.Join(environmentPath, "binary")
-	var cmd = exec.Command(b.pathGoBinary, "build", "-ldflags", l.string(), "-o", binaryPath, "-tags", e.Tags, b.pathBuild)
-	cmd.Env = []string{
-		"GOARCH=" + e.Arch,
-		"GOOS=" + e.OS,
-		"GOPATH=" + os.Getenv("GOPATH"),
-		"PATH=" + os.Getenv("PATH"),
+	// Build windows console binary
+	var debugBinaryPath string
+	if e.OS == "windows" && c.WindowsSubsystem == windowsSubsystemBoth {
+		debugBinaryPath = filepath.Join(environmentPath, "binary-debug")
+		if err = b.goBuild(e, c, debugBinaryPath, false); err != nil {
+			err = errors.Wrap(err, "building console binary failed")
+			return
+		}
 	}
 
-	// Exec
-	var o []byte
-	astilog.Debugf("Executing %s", strings.Join(cmd.Args, " "))
-	if o, err = cmd.CombinedOutput(); err != nil {
-		err = errors.Wrapf(err, "building failed: %s", o)
-		return
+	// Append vendor zips
+	if b.vendorMode == vendorModePayload {
+		for _, p := range []string{binaryPath, debugBinaryPath} {
+			if len(p) == 0 {
+				continue
+			}
+			if err = b.appendPayload(p, b.vendorAssets(e.OS, e.Arch)); err != nil {
+				err = errors.Wrapf(err, "appending payload to %s failed", p)
+				return
+			}
+		}
 	}
 
 	// Finish bundle based on OS
@@ -498,7 +1513,9 @@ func (b *Bundler) bundle(e ConfigurationEnvironment) (err error) {
 	case "linux":
 		err = b.finishLinux(environmentPath, binaryPath)
 	case "windows":
-		err = b.finishWindows(environmentPath, binaryPath)
+		if err = b.finishWindows(environmentPath, binaryPath, ""); err == nil && len(debugBinaryPath) > 0 {
+			err = b.finishWindows(environmentPath, debugBinaryPath, "-debug")
+		}
 	default:
 		err = fmt.Errorf("OS %s is not yet implemented", e.OS)
 	}
@@ -602,10 +1619,10 @@ func (b *Bundler) finishLinux(environmentPath, binaryPath string) (err error) {
 	return
 }
 
-// finishWindows finishes bundling for a linux system
-func (b *Bundler) finishWindows(environmentPath, binaryPath string) (err error) {
+// finishWindows finishes bundling for a windows system
+func (b *Bundler) finishWindows(environmentPath, binaryPath, suffix string) (err error) {
 	// Move binary
-	var windowsBinaryPath = filepath.Join(environmentPath, b.appName+".exe")
+	var windowsBinaryPath = filepath.Join(environmentPath, b.appName+suffix+".exe")
 	astilog.Debugf("Moving %s to %s", binaryPath, windowsBinaryPath)
 	if err = astios.Move(b.ctx, binaryPath, windowsBinaryPath); err != nil {
 		err = errors.Wrapf(err, "moving %s to %s failed", binaryPath, windowsBinaryPath)
@@ -0,0 +1,147 @@
+package astibundler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// ConfigurationBindCompression represents the compression of the bound files. The resources level is only supported by
+// the embed backend, the bindata backend compresses resources with its default level.
+type ConfigurationBindCompression struct {
+	// Gzip level of the resources, from 0 (no compression) to 9 (best compression). Default is -1 (default compression).
+	// Embed backend only
+	Resources *int `json:"resources"`
+	// Gzip level of the vendor zips. Default is 0 since they're already compressed
+	Vendor *int `json:"vendor"`
+}
+
+// compressionLevel returns the gzip level to use for a config value
+func compressionLevel(l *int, def int) (o int, err error) {
+	o = def
+	if l != nil {
+		o = *l
+	}
+	if o < gzip.DefaultCompression || o > gzip.BestCompression {
+		err = fmt.Errorf("gzip level %d is invalid", o)
+		return
+	}
+	return
+}
+
+// assetGroup returns the group of an asset ("resources" or "vendor")
+func assetGroup(a asset) string {
+	if a.vendor {
+		return "vendor"
+	}
+	return "resources"
+}
+
+// compressionLevel returns the gzip level of an asset
+func (b *Bundler) compressionLevel(a asset) int {
+	if assetGroup(a) == "vendor" {
+		return b.compressionVendor
+	}
+	return b.compressionResources
+}
+
+// gzipFile compresses a file into another
+func gzipFile(src, dst string, level int) (err error) {
+	// Open source
+	var s *os.File
+	if s, err = os.Open(src); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", src)
+		return
+	}
+	defer s.Close()
+
+	// Create destination
+	var d *os.File
+	if d, err = os.Create(dst); err != nil {
+		err = errors.Wrapf(err, "creating %s failed", dst)
+		return
+	}
+	defer d.Close()
+
+	// Compress
+	var w *gzip.Writer
+	if w, err = gzip.NewWriterLevel(d, level); err != nil {
+		err = errors.Wrapf(err, "creating gzip writer with level %d failed", level)
+		return
+	}
+	if _, err = io.Copy(w, s); err != nil {
+		err = errors.Wrapf(err, "compressing %s into %s failed", src, dst)
+		return
+	}
+	if err = w.Close(); err != nil {
+		err = errors.Wrap(err, "closing gzip writer failed")
+		return
+	}
+	if err = d.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s failed", dst)
+		return
+	}
+	return
+}
+
+// bindStats represents statistics about the bound files of a group
+type bindStats struct {
+	compression string
+	count       int
+	duration    time.Duration
+	size        int64
+	sizeStored  int64 // 0 if unknown
+}
+
+// bindReport represents statistics about the bound files indexed by group
+type bindReport map[string]*bindStats
+
+// add adds a bound file to the report
+func (r bindReport) add(a asset, compression string, size, sizeStored int64, d time.Duration) {
+	var g = assetGroup(a)
+	if _, ok := r[g]; !ok {
+		r[g] = &bindStats{}
+	}
+	r[g].compression = compression
+	r[g].count++
+	r[g].duration += d
+	r[g].size += size
+	r[g].sizeStored += sizeStored
+}
+
+// log logs the report
+func (r bindReport) log() {
+	var gs []string
+	for g := range r {
+		gs = append(gs, g)
+	}
+	sort.Strings(gs)
+	for _, g := range gs {
+		var s = r[g]
+		if s.sizeStored > 0 && s.sizeStored != s.size {
+			astilog.Infof("Bound %d %s files of %s compressed to %s (%s) in %s", s.count, g, humanBytes(s.size), humanBytes(s.sizeStored), s.compression, s.duration)
+		} else {
+			astilog.Infof("Bound %d %s files of %s (compression: %s) in %s", s.count, g, humanBytes(s.size), s.compression, s.duration)
+		}
+	}
+}
+
+// humanBytes returns a human readable size
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	var d, e = int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		d *= unit
+		e++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(d), "KMGTPE"[e])
+}
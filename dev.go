@@ -0,0 +1,191 @@
+package astibundler
+
+import (
+	"bytes"
+	"go/format"
+	"io/ioutil"
+	"path"
+	"text/template"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// tmplDev is the template of the file generated in dev mode.
+// Resources are read from disk at runtime so that changes are taken into account without binding data again.
+var tmplDev = template.Must(template.New("dev").Parse(`// Code generated by astilectron-bundler. DO NOT EDIT.
+// Assets are read from disk, this file is meant for development only.
+
+//go:build {{ .BuildConstraint }}
+
+package {{ .Package }}
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// _bindataDirs lists, by order of precedence, the folders assets are read from and the prefix they're bound under
+var _bindataDirs = []struct{ prefix, path string }{ {{- range .Dirs }}
+	{ {{ printf "%q" .Prefix }}, {{ printf "%q" .Path }} },{{ end }}
+}
+
+// _bindataFiles indexes the paths of the files read as is by asset name
+var _bindataFiles = map[string]string{ {{- range .Files }}
+	{{ printf "%q" .Name }}: {{ printf "%q" .Path }},{{ end }}
+}
+
+// _bindataRel returns the path of an asset relative to a prefix
+func _bindataRel(prefix, name string) (string, bool) {
+	if name == prefix {
+		return "", true
+	} else if strings.HasPrefix(name, prefix+"/") {
+		return strings.TrimPrefix(name, prefix+"/"), true
+	}
+	return "", false
+}
+
+// Asset loads and returns the asset for the given name.
+// It returns an error if the asset could not be found or could not be loaded.
+func Asset(name string) ([]byte, error) {
+	name = filepath.ToSlash(name)
+	if p, ok := _bindataFiles[name]; ok {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading asset %s at %s: %v", name, p, err)
+		}
+		return b, nil
+	}
+	for _, d := range _bindataDirs {
+		if rel, ok := _bindataRel(d.prefix, name); ok && len(rel) > 0 {
+			if b, err := ioutil.ReadFile(filepath.Join(d.path, filepath.FromSlash(rel))); err == nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Asset %s not found", name)
+}
+
+// AssetNames returns the names of the assets.
+func AssetNames() (names []string) {
+	var m = make(map[string]bool)
+	for n := range _bindataFiles {
+		m[n] = true
+	}
+	for _, d := range _bindataDirs {
+		filepath.Walk(d.path, func(p string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				if rel, err := filepath.Rel(d.path, p); err == nil {
+					m[path.Join(d.prefix, filepath.ToSlash(rel))] = true
+				}
+			}
+			return nil
+		})
+	}
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return
+}
+
+// AssetDir returns the file names below a certain directory.
+// If name is empty, the root directory is used.
+func AssetDir(name string) ([]string, error) {
+	name = strings.Trim(filepath.ToSlash(name), "/")
+	var m = make(map[string]bool)
+	var children = func(n string) {
+		if len(name) == 0 {
+			m[strings.SplitN(n, "/", 2)[0]] = true
+		} else if rel, ok := _bindataRel(name, n); ok && len(rel) > 0 {
+			m[strings.SplitN(rel, "/", 2)[0]] = true
+		}
+	}
+	for n := range _bindataFiles {
+		children(n)
+	}
+	for _, d := range _bindataDirs {
+		if rel, ok := _bindataRel(d.prefix, name); ok {
+			if fs, err := ioutil.ReadDir(filepath.Join(d.path, filepath.FromSlash(rel))); err == nil {
+				for _, f := range fs {
+					m[f.Name()] = true
+				}
+			}
+		} else {
+			children(d.prefix)
+		}
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("Asset %s not found", name)
+	}
+	var names []string
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// AssetDigest returns the SHA-256 of the asset computed from the file read on disk
+func AssetDigest(name string) ([32]byte, error) {
+	b, err := Asset(name)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("AssetDigest %s not found", name)
+	}
+	return sha256.Sum256(b), nil
+}
+` + tmplAssetHelpers))
+
+// bindDev generates a bind file reading the resources from disk at runtime and the vendor zips and metadata from
+// the cache
+func (b *Bundler) bindDev(oS, arch, bc string, ma asset) (err error) {
+	// Build dirs by order of precedence
+	type dir struct{ Path, Prefix string }
+	var ds []dir
+	for _, suffix := range []string{"_" + oS + "_" + arch, "_" + oS, ""} {
+		for _, r := range b.pathResources {
+			ds = append(ds, dir{Path: r.path + suffix, Prefix: path.Join("resources", r.prefix)})
+		}
+	}
+
+	// Build files
+	type file struct{ Name, Path string }
+	var fs []file
+	for _, a := range append(b.vendorAssets(oS, arch), ma) {
+		fs = append(fs, file{Name: a.name, Path: a.path})
+	}
+
+	// Execute template
+	var buf = &bytes.Buffer{}
+	if err = tmplDev.Execute(buf, map[string]interface{}{
+		"BuildConstraint": bc,
+		"Dirs":            ds,
+		"Files":           fs,
+		"Package":         b.bindPackage,
+	}); err != nil {
+		err = errors.Wrap(err, "executing dev template failed")
+		return
+	}
+
+	// Format
+	var c []byte
+	if c, err = format.Source(buf.Bytes()); err != nil {
+		err = errors.Wrap(err, "formatting generated code failed")
+		return
+	}
+
+	// Write
+	var p = b.pathBindData(oS, arch)
+	astilog.Debugf("Generating %s", p)
+	if err = ioutil.WriteFile(p, c, 0666); err != nil {
+		err = errors.Wrapf(err, "writing %s failed", p)
+		return
+	}
+	return
+}
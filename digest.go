@@ -0,0 +1,99 @@
+package astibundler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// tmplDigests is the template of the digests added to the bind files generated by the bindata and embed backends.
+// It only relies on packages imported by every bind file since it's appended to the file generated by go-bindata.
+const tmplDigests = `
+// _bindataDigests indexes the SHA-256 of the assets by name
+var _bindataDigests = map[string][32]byte{ {{- range .Digests }}
+	{{ printf "%q" .Name }}: { {{- .Bytes -}} },{{ end }}
+}
+
+// AssetDigest returns the SHA-256 of the asset, vendor zips included even if they're not bound
+func AssetDigest(name string) ([32]byte, error) {
+	d, ok := _bindataDigests[filepath.ToSlash(name)]
+	if !ok {
+		return d, fmt.Errorf("AssetDigest %s not found", name)
+	}
+	return d, nil
+}
+`
+
+// tmplBindataDigests is the template of the digests appended to the file generated by go-bindata
+var tmplBindataDigests = template.Must(template.New("digests").Parse(tmplDigests))
+
+// assetDigest represents the SHA-256 of an asset
+type assetDigest struct {
+	Bytes string // Go representation of the bytes, e.g. "0x01, 0x02"
+	Hex   string
+	Name  string
+}
+
+// digestAssets computes the SHA-256 of assets
+func digestAssets(as []asset) (ds []assetDigest, err error) {
+	for _, a := range as {
+		// Open
+		var f *os.File
+		if f, err = os.Open(a.path); err != nil {
+			err = errors.Wrapf(err, "opening %s failed", a.path)
+			return
+		}
+
+		// Hash
+		var h = sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			err = errors.Wrapf(err, "hashing %s failed", a.path)
+			return
+		}
+
+		// Format
+		var bs []string
+		var s = h.Sum(nil)
+		for _, b := range s {
+			bs = append(bs, fmt.Sprintf("0x%02x", b))
+		}
+		ds = append(ds, assetDigest{Bytes: strings.Join(bs, ", "), Hex: hex.EncodeToString(s), Name: a.name})
+	}
+	return
+}
+
+// appendDigests appends the digests to a file generated by go-bindata
+func appendDigests(path string, ds []assetDigest) (err error) {
+	// Execute template
+	var buf = &bytes.Buffer{}
+	if err = tmplBindataDigests.Execute(buf, map[string]interface{}{"Digests": ds}); err != nil {
+		err = errors.Wrap(err, "executing digests template failed")
+		return
+	}
+
+	// Append
+	var f *os.File
+	if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", path)
+		return
+	}
+	defer f.Close()
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		err = errors.Wrapf(err, "appending digests to %s failed", path)
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s failed", path)
+		return
+	}
+	return
+}
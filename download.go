@@ -0,0 +1,131 @@
+package astibundler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/pkg/errors"
+)
+
+// Default timeout of the electron download performed at runtime
+const defaultRuntimeDownloadTimeout = 30 * time.Minute
+
+// DownloadOptions represents the options of the electron download performed at runtime by the download provisioner
+type DownloadOptions struct {
+	Client     *http.Client  // Defaults to a client using the environment proxies with a 30 minutes timeout
+	Retries    int           // Defaults to 5, a negative value disables retries
+	RetryDelay time.Duration // Defaults to 1s, doubled after each retry
+}
+
+// defaults returns the options with default values set
+func (o DownloadOptions) defaults() DownloadOptions {
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: defaultRuntimeDownloadTimeout}
+	}
+	if o.Retries == 0 {
+		o.Retries = defaultDownloadRetries
+	} else if o.Retries < 0 {
+		o.Retries = 0
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = defaultDownloadRetryDelay
+	}
+	return o
+}
+
+// vendorDownload represents a vendor zip downloaded at runtime
+type vendorDownload struct {
+	SHA256 string `json:"sha256"`
+	URL    string `json:"url"`
+}
+
+// DownloadDisembedder returns a disembed function downloading the electron zip whose URL and SHA-256 have been bound
+// by the bundler in "download" vendor mode. Files that have been bound are disembedded using the disembed function.
+func DownloadDisembedder(disembedFunc func(string) ([]byte, error), o DownloadOptions) func(string) ([]byte, error) {
+	o = o.defaults()
+	return func(name string) (b []byte, err error) {
+		// The file has been bound or is not a zip
+		if b, err = disembedFunc(name); err == nil || !strings.HasSuffix(name, ".zip") {
+			return
+		}
+
+		// Disembed download
+		var n = downloadName(name)
+		var c []byte
+		if c, err = disembedFunc(n); err != nil {
+			err = errors.Wrapf(err, "disembedding %s failed", n)
+			return
+		}
+		var d vendorDownload
+		if err = json.Unmarshal(c, &d); err != nil {
+			err = errors.Wrapf(err, "unmarshaling %s failed", n)
+			return
+		}
+
+		// Loop through attempts
+		var delay = o.RetryDelay
+		for attempt := 0; ; attempt++ {
+			// Download
+			if b, err = downloadRuntime(o.Client, d); err == nil {
+				return
+			}
+
+			// No more retries
+			if attempt >= o.Retries || !retryable(err) {
+				err = errors.Wrapf(err, "downloading %s failed after %d attempts", d.URL, attempt+1)
+				return
+			}
+
+			// Wait
+			time.Sleep(delay)
+			if delay *= 2; delay > maxDownloadRetryDelay {
+				delay = maxDownloadRetryDelay
+			}
+		}
+	}
+}
+
+// downloadRuntime downloads a vendor zip and verifies its SHA-256
+func downloadRuntime(c *http.Client, d vendorDownload) (o []byte, err error) {
+	// Get
+	var resp *http.Response
+	if resp, err = c.Get(d.URL); err != nil {
+		err = downloadNetworkError{err: errors.Wrapf(err, "getting %s failed", d.URL)}
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode > 299 {
+		err = downloadStatusError{code: resp.StatusCode, src: d.URL}
+		return
+	}
+
+	// Read
+	var buf = &bytes.Buffer{}
+	var h = sha256.New()
+	if _, err = io.Copy(io.MultiWriter(buf, h), resp.Body); err != nil {
+		err = downloadNetworkError{err: errors.Wrapf(err, "reading body of %s failed", d.URL)}
+		return
+	}
+
+	// Verify
+	if s := hex.EncodeToString(h.Sum(nil)); s != d.SHA256 {
+		err = fmt.Errorf("astibundler: sha256 of %s is %s, expected %s", d.URL, s, d.SHA256)
+		return
+	}
+	o = buf.Bytes()
+	return
+}
+
+// NewDownloadProvisioner builds a provisioner downloading electron at first run from the URL bound by the bundler in
+// "download" vendor mode, and verifying it against the bound SHA-256
+func NewDownloadProvisioner(disembedFunc func(string) ([]byte, error), o DownloadOptions) astilectron.Provisioner {
+	return NewProvisioner(DownloadDisembedder(disembedFunc, o))
+}
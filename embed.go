@@ -0,0 +1,419 @@
+package astibundler
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// Bind backends
+const (
+	bindBackendBindata = "bindata"
+	bindBackendEmbed   = "embed"
+)
+
+// tmplAssetHelpers contains the functions shared by the generated bind files
+const tmplAssetHelpers = `
+// MustAsset is like Asset but panics when Asset would return an error.
+func MustAsset(name string) []byte {
+	b, err := Asset(name)
+	if err != nil {
+		panic("asset: Asset(" + name + "): " + err.Error())
+	}
+	return b
+}
+
+// RestoreAsset restores an asset under the given directory
+func RestoreAsset(dir, name string) error {
+	b, err := Asset(name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), os.FileMode(0755)); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), b, os.FileMode(0644))
+}
+
+// RestoreAssets restores an asset under the given directory recursively
+func RestoreAssets(dir, name string) error {
+	children, err := AssetDir(name)
+	// File
+	if err != nil {
+		return RestoreAsset(dir, name)
+	}
+	// Dir
+	for _, child := range children {
+		if err = RestoreAssets(dir, filepath.Join(name, child)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`
+
+// tmplEmbed is the template of the file generated by the embed backend.
+// It exposes the same API as go-bindata so that it can be used with NewProvisioner and the bootstrap.
+var tmplEmbed = template.Must(template.New("embed").Parse(`// Code generated by astilectron-bundler. DO NOT EDIT.
+
+//go:build {{ .BuildConstraint }}
+
+package {{ .Package }}
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed all:{{ .Dir }}
+var _bindataFS embed.FS
+
+// _bindataRoot is the root of the assets in _bindataFS
+const _bindataRoot = "{{ .Dir }}"
+
+// _bindataCompressed lists the gzipped assets
+var _bindataCompressed = map[string]bool{ {{- range .Compressed }}
+	{{ printf "%q" . }}: true,{{ end }}
+}
+
+// Asset loads and returns the asset for the given name.
+// It returns an error if the asset could not be found or could not be loaded.
+func Asset(name string) ([]byte, error) {
+	name = filepath.ToSlash(name)
+	b, err := _bindataFS.ReadFile(path.Join(_bindataRoot, name))
+	if err != nil || name == _bindataMarker {
+		return nil, fmt.Errorf("Asset %s not found", name)
+	}
+	if !_bindataCompressed[name] {
+		return b, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+	defer r.Close()
+	if b, err = ioutil.ReadAll(r); err != nil {
+		return nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+	return b, nil
+}
+
+// _bindataMarker is the file marking _bindataRoot as generated, which is not an asset
+const _bindataMarker = "{{ .Marker }}"
+
+// AssetNames returns the names of the assets.
+func AssetNames() (names []string) {
+	fs.WalkDir(_bindataFS, _bindataRoot, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && p != path.Join(_bindataRoot, _bindataMarker) {
+			names = append(names, strings.TrimPrefix(p, _bindataRoot+"/"))
+		}
+		return nil
+	})
+	return
+}
+
+// AssetDir returns the file names below a certain directory embedded in the file.
+// If name is empty, the root directory is used.
+func AssetDir(name string) ([]string, error) {
+	es, err := _bindataFS.ReadDir(path.Join(_bindataRoot, filepath.ToSlash(name)))
+	if err != nil {
+		return nil, fmt.Errorf("Asset %s not found", name)
+	}
+	var names []string
+	for _, e := range es {
+		if strings.Trim(name, "/") == "" && e.Name() == _bindataMarker {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+` + tmplAssetHelpers + tmplDigests))
+
+// pathBindEmbed returns the path of the directory where the embed backend stages the assets
+func (b *Bundler) pathBindEmbed(oS, arch string) string {
+	return filepath.Join(b.pathBindOutput, fmt.Sprintf("bind_%s_%s", oS, arch))
+}
+
+// generatedMarkerName is the name of the file marking a directory as generated by the bundler
+const generatedMarkerName = ".astibundler-generated"
+
+// isGeneratedDir checks whether a directory has been generated by the bundler
+func isGeneratedDir(path string) (o bool, err error) {
+	if _, err = os.Stat(filepath.Join(path, generatedMarkerName)); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = errors.Wrapf(err, "stating marker in %s failed", path)
+		return
+	}
+	o = true
+	return
+}
+
+// removeAll removes a path
+func removeAll(path string) (err error) {
+	if _, errStat := os.Stat(path); os.IsNotExist(errStat) {
+		return
+	}
+	astilog.Debugf("Removing %s", path)
+	if err = os.RemoveAll(path); err != nil {
+		err = errors.Wrapf(err, "removing %s failed", path)
+		return
+	}
+	return
+}
+
+// resetBindEmbed resets the embed directory, making sure it's not a folder of the project
+func resetBindEmbed(d string) (err error) {
+	if _, errStat := os.Stat(d); errStat == nil {
+		var g bool
+		if g, err = isGeneratedDir(d); err != nil {
+			err = errors.Wrapf(err, "checking whether %s is generated failed", d)
+			return
+		} else if !g {
+			err = fmt.Errorf("%s already exists and has not been generated by the bundler", d)
+			return
+		}
+		if err = removeAll(d); err != nil {
+			return
+		}
+	}
+	astilog.Debugf("Creating %s", d)
+	if err = os.MkdirAll(d, 0777); err != nil {
+		err = errors.Wrapf(err, "mkdirall %s failed", d)
+		return
+	}
+	if err = ioutil.WriteFile(filepath.Join(d, generatedMarkerName), []byte("Generated by astilectron-bundler\n"), 0666); err != nil {
+		err = errors.Wrapf(err, "writing marker in %s failed", d)
+		return
+	}
+	return
+}
+
+// bindEmbed binds the data using go:embed
+func (b *Bundler) bindEmbed(oS, arch, bc string, as []asset, ds []assetDigest) (err error) {
+	// Reset the embed directory
+	var d = b.pathBindEmbed(oS, arch)
+	if err = resetBindEmbed(d); err != nil {
+		return
+	}
+
+	// Stage assets
+	astilog.Debugf("Staging assets into %s", d)
+	var r bindReport
+	var compressed []string
+	if r, compressed, err = b.stageAssets(as, d, true); err != nil {
+		err = errors.Wrapf(err, "staging assets into %s failed", d)
+		return
+	}
+	r.log()
+
+	// Execute template
+	var buf = &bytes.Buffer{}
+	if err = tmplEmbed.Execute(buf, map[string]interface{}{
+		"BuildConstraint": bc,
+		"Compressed":      compressed,
+		"Digests":         ds,
+		"Dir":             filepath.Base(d),
+		"Marker":          generatedMarkerName,
+		"Package":         b.bindPackage,
+	}); err != nil {
+		err = errors.Wrap(err, "executing embed template failed")
+		return
+	}
+
+	// Format
+	var c []byte
+	if c, err = format.Source(buf.Bytes()); err != nil {
+		err = errors.Wrap(err, "formatting generated code failed")
+		return
+	}
+
+	// Write
+	var p = b.pathBindData(oS, arch)
+	astilog.Debugf("Generating %s", p)
+	if err = ioutil.WriteFile(p, c, 0666); err != nil {
+		err = errors.Wrapf(err, "writing %s failed", p)
+		return
+	}
+	return
+}
+
+// tmplBindataVendor is the template of the file binding the vendor files next to the file generated by go-bindata.
+// Vendor zips are already compressed, therefore they're embedded with go:embed instead of being gzipped again by
+// go-bindata, and registered in the go-bindata tables so that Asset, AssetDir and AssetNames return them.
+var tmplBindataVendor = template.Must(template.New("bindata_vendor").Parse(`// Code generated by astilectron-bundler. DO NOT EDIT.
+
+//go:build {{ .BuildConstraint }}
+
+package {{ .Package }}
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+{{ range $i, $f := .Files }}
+//go:embed {{ printf "%q" $f.Path }}
+var _bindataVendor{{ $i }} []byte
+{{ end }}
+func init() { {{- range $i, $f := .Files }}
+	_bindataVendorRegister({{ printf "%q" $f.Name }}, _bindataVendor{{ $i }}, {{ $f.Compressed }}){{ end }}
+}
+
+// _bindataVendorRegister registers a vendor file in the go-bindata tables
+func _bindataVendorRegister(name string, b []byte, compressed bool) {
+	var fn = func() (*asset, error) {
+		var o = b
+		if compressed {
+			r, err := gzip.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return nil, fmt.Errorf("Read %q: %v", name, err)
+			}
+			defer r.Close()
+			if o, err = ioutil.ReadAll(r); err != nil {
+				return nil, fmt.Errorf("Read %q: %v", name, err)
+			}
+		}
+		return &asset{bytes: o, info: bindataFileInfo{name: name, size: int64(len(o)), mode: os.FileMode(0644), modTime: time.Unix(0, 0)}}, nil
+	}
+	_bindata[name] = fn
+	var node = _bintree
+	for _, p := range strings.Split(name, "/") {
+		if node.Children == nil {
+			node.Children = make(map[string]*bintree)
+		}
+		if _, ok := node.Children[p]; !ok {
+			node.Children[p] = &bintree{Children: make(map[string]*bintree)}
+		}
+		node = node.Children[p]
+	}
+	node.Func = fn
+}
+`))
+
+// bindataVendorFile represents a vendor file bound next to the file generated by go-bindata
+type bindataVendorFile struct {
+	Compressed bool
+	Name       string
+	Path       string // Slash separated path relative to the bind output path
+}
+
+// pathBindDataVendor returns the path of the file binding the vendor files with the bindata backend
+func (b *Bundler) pathBindDataVendor(oS, arch string) string {
+	return filepath.Join(b.pathBindOutput, fmt.Sprintf("bind_%s_%s_vendor.go", oS, arch))
+}
+
+// removeBindDataVendor removes the file and the directory binding the vendor files with the bindata backend, if
+// they have been generated
+func (b *Bundler) removeBindDataVendor(oS, arch string) (err error) {
+	// Remove file
+	var p = b.pathBindDataVendor(oS, arch)
+	if _, errStat := os.Stat(p); errStat == nil {
+		var g bool
+		if g, err = isGenerated(p); err != nil {
+			err = errors.Wrapf(err, "checking whether %s is generated failed", p)
+			return
+		} else if !g {
+			err = fmt.Errorf("%s already exists and has not been generated by the bundler", p)
+			return
+		}
+		if err = removeAll(p); err != nil {
+			return
+		}
+	}
+
+	// Remove directory
+	var g bool
+	var d = b.pathBindEmbed(oS, arch)
+	if g, err = isGeneratedDir(d); err != nil {
+		err = errors.Wrapf(err, "checking whether %s is generated failed", d)
+		return
+	} else if g {
+		if err = removeAll(d); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// bindDataVendor binds the vendor files next to the file generated by go-bindata, without compressing them unless
+// a vendor compression level is set
+func (b *Bundler) bindDataVendor(oS, arch, bc string, as []asset) (r bindReport, err error) {
+	// Reset the embed directory
+	var d = b.pathBindEmbed(oS, arch)
+	if err = resetBindEmbed(d); err != nil {
+		return
+	}
+
+	// Stage assets
+	astilog.Debugf("Staging vendor assets into %s", d)
+	var compressed []string
+	if r, compressed, err = b.stageAssets(as, d, true); err != nil {
+		err = errors.Wrapf(err, "staging vendor assets into %s failed", d)
+		return
+	}
+
+	// Build files
+	var cs = make(map[string]bool)
+	for _, n := range compressed {
+		cs[n] = true
+	}
+	var fs []bindataVendorFile
+	for _, a := range as {
+		fs = append(fs, bindataVendorFile{
+			Compressed: cs[a.name],
+			Name:       a.name,
+			Path:       filepath.Base(d) + "/" + a.name,
+		})
+	}
+
+	// Execute template
+	var buf = &bytes.Buffer{}
+	if err = tmplBindataVendor.Execute(buf, map[string]interface{}{
+		"BuildConstraint": bc,
+		"Files":           fs,
+		"Package":         b.bindPackage,
+	}); err != nil {
+		err = errors.Wrap(err, "executing bindata vendor template failed")
+		return
+	}
+
+	// Format
+	var c []byte
+	if c, err = format.Source(buf.Bytes()); err != nil {
+		err = errors.Wrap(err, "formatting generated code failed")
+		return
+	}
+
+	// Write
+	var p = b.pathBindDataVendor(oS, arch)
+	astilog.Debugf("Generating %s", p)
+	if err = ioutil.WriteFile(p, c, 0666); err != nil {
+		err = errors.Wrapf(err, "writing %s failed", p)
+		return
+	}
+	return
+}
@@ -0,0 +1,541 @@
+package astibundler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// ConfigurationDownload represents the downloads performed while bundling. Durations are go durations (e.g. "30s")
+type ConfigurationDownload struct {
+	// PEM encoded CA certificates trusted on top of the system ones
+	CAFiles []string `json:"ca_files"`
+	// Certificates presented to servers requiring client authentication
+	ClientCertificates []ConfigurationClientCertificate `json:"client_certificates"`
+	// Timeout of receiving the response headers. Default is 30s
+	ConnectTimeout string `json:"connect_timeout"`
+	// Timeout of receiving data once the download has started. Default is 1m
+	IdleTimeout string `json:"idle_timeout"`
+	// Skip the verification of the servers certificates. Only use it for internal mirrors
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	// URL of the proxy (e.g. "http://proxy.example.com:3128"). Default is the proxy set in the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables
+	ProxyURL string `json:"proxy_url"`
+	// Number of retries of a failed download. Default is 5
+	Retries *int `json:"retries"`
+	// Delay before the first retry, doubled after each retry. Default is 1s
+	RetryDelay string `json:"retry_delay"`
+	// Timeout of a download including its retries. Default is no timeout
+	Timeout string `json:"timeout"`
+}
+
+// ConfigurationClientCertificate represents a PEM encoded client certificate and its key
+type ConfigurationClientCertificate struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// Download defaults
+const (
+	defaultDownloadConnectTimeout = 30 * time.Second
+	defaultDownloadIdleTimeout    = time.Minute
+	defaultDownloadRetries        = 5
+	defaultDownloadRetryDelay     = time.Second
+	maxDownloadRetryDelay         = time.Minute
+)
+
+// downloadOptions represents the parsed download configuration
+type downloadOptions struct {
+	connectTimeout time.Duration
+	idleTimeout    time.Duration
+	retries        int
+	retryDelay     time.Duration
+	timeout        time.Duration
+}
+
+// newDownloadOptions parses the download configuration
+func newDownloadOptions(c ConfigurationDownload) (o downloadOptions, err error) {
+	// Durations
+	for _, d := range []struct {
+		def   time.Duration
+		dst   *time.Duration
+		name  string
+		value string
+	}{
+		{def: defaultDownloadConnectTimeout, dst: &o.connectTimeout, name: "connect timeout", value: c.ConnectTimeout},
+		{def: defaultDownloadIdleTimeout, dst: &o.idleTimeout, name: "idle timeout", value: c.IdleTimeout},
+		{def: defaultDownloadRetryDelay, dst: &o.retryDelay, name: "retry delay", value: c.RetryDelay},
+		{dst: &o.timeout, name: "timeout", value: c.Timeout},
+	} {
+		*d.dst = d.def
+		if len(d.value) == 0 {
+			continue
+		}
+		if *d.dst, err = time.ParseDuration(d.value); err != nil {
+			err = errors.Wrapf(err, "parsing %s %s failed", d.name, d.value)
+			return
+		} else if *d.dst < 0 {
+			err = fmt.Errorf("%s %s is invalid", d.name, d.value)
+			return
+		}
+	}
+
+	// Retries
+	o.retries = defaultDownloadRetries
+	if c.Retries != nil {
+		if *c.Retries < 0 {
+			err = fmt.Errorf("retries %d is invalid", *c.Retries)
+			return
+		}
+		o.retries = *c.Retries
+	}
+	return
+}
+
+// newDownloadClient builds the HTTP client of the downloads based on the proxy and TLS configuration
+func newDownloadClient(c ConfigurationDownload) (o *http.Client, err error) {
+	// Clone the default transport so that its timeouts and proxy from environment are kept
+	var t = http.DefaultTransport.(*http.Transport).Clone()
+
+	// Proxy
+	if len(c.ProxyURL) > 0 {
+		var u *url.URL
+		if u, err = url.Parse(c.ProxyURL); err != nil {
+			err = errors.Wrapf(err, "parsing proxy url %s failed", c.ProxyURL)
+			return
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			err = fmt.Errorf("proxy url %s is invalid: scheme must be http, https or socks5", c.ProxyURL)
+			return
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	// TLS
+	if len(c.CAFiles) > 0 || len(c.ClientCertificates) > 0 || c.InsecureSkipVerify {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+		if c.InsecureSkipVerify {
+			astilog.Warnf("Servers certificates won't be verified during downloads")
+		}
+
+		// CA files
+		if len(c.CAFiles) > 0 {
+			if t.TLSClientConfig.RootCAs, err = x509.SystemCertPool(); err != nil || t.TLSClientConfig.RootCAs == nil {
+				astilog.Debugf("Loading system cert pool failed, only trusting CA files: %v", err)
+				t.TLSClientConfig.RootCAs = x509.NewCertPool()
+				err = nil
+			}
+			for _, f := range c.CAFiles {
+				// Read
+				var p string
+				if p, err = absPath(f, nil); err != nil {
+					return
+				}
+				var b []byte
+				if b, err = ioutil.ReadFile(p); err != nil {
+					err = errors.Wrapf(err, "reading %s failed", p)
+					return
+				}
+
+				// Append
+				if !t.TLSClientConfig.RootCAs.AppendCertsFromPEM(b) {
+					err = fmt.Errorf("no PEM encoded certificate found in %s", p)
+					return
+				}
+			}
+		}
+
+		// Client certificates
+		for _, cc := range c.ClientCertificates {
+			var certFile, keyFile string
+			if certFile, err = absPath(cc.CertFile, nil); err != nil {
+				return
+			}
+			if keyFile, err = absPath(cc.KeyFile, nil); err != nil {
+				return
+			}
+			var cert tls.Certificate
+			if cert, err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+				err = errors.Wrapf(err, "loading client certificate %s with key %s failed", certFile, keyFile)
+				return
+			}
+			t.TLSClientConfig.Certificates = append(t.TLSClientConfig.Certificates, cert)
+		}
+	}
+	o = &http.Client{Transport: t}
+	return
+}
+
+// Interval between two progress reports of a download
+const downloadProgressInterval = 500 * time.Millisecond
+
+// DownloadProgress represents the progress of a download
+type DownloadProgress struct {
+	Done       bool
+	Downloaded int64         // Bytes, including the ones downloaded by previous attempts
+	ETA        time.Duration // 0 if unknown
+	Name       string        // Base name of the destination
+	Throughput float64       // Bytes per second
+	Total      int64         // Bytes, -1 if unknown
+	URL        string
+}
+
+// Percent returns the percentage of the download that is done, -1 if unknown
+func (p DownloadProgress) Percent() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return 100 * float64(p.Downloaded) / float64(p.Total)
+}
+
+// String implements the fmt.Stringer interface
+func (p DownloadProgress) String() string {
+	var s = p.Name + ": "
+	if p.Total >= 0 {
+		s += fmt.Sprintf("%.1f%% of %s", p.Percent(), humanBytes(p.Total))
+	} else {
+		s += humanBytes(p.Downloaded)
+	}
+	if p.Throughput > 0 {
+		s += fmt.Sprintf(" at %s/s", humanBytes(int64(p.Throughput)))
+	}
+	if p.Done {
+		s += ", done"
+	} else if p.ETA > 0 {
+		s += fmt.Sprintf(", ETA %s", p.ETA.Round(time.Second))
+	}
+	return s
+}
+
+// downloadProgressReporter reports the progress of a download attempt to the progress callback
+type downloadProgressReporter struct {
+	fn    func(p DownloadProgress)
+	last  time.Time
+	n     int64 // Bytes downloaded by the attempt
+	p     DownloadProgress
+	start time.Time
+}
+
+// newDownloadProgressReporter builds a progress reporter for an attempt resuming at an offset
+func newDownloadProgressReporter(fn func(p DownloadProgress), src, dst string, offset, total int64) *downloadProgressReporter {
+	return &downloadProgressReporter{
+		fn: fn,
+		p: DownloadProgress{
+			Downloaded: offset,
+			Name:       filepath.Base(dst),
+			Total:      total,
+			URL:        src,
+		},
+		start: time.Now(),
+	}
+}
+
+// add adds downloaded bytes and reports the progress if needed
+func (r *downloadProgressReporter) add(n int64, done bool) {
+	// Update
+	r.n += n
+	r.p.Done = done
+	r.p.Downloaded += n
+	if done && r.p.Total < 0 {
+		r.p.Total = r.p.Downloaded
+	}
+
+	// Throttle
+	var now = time.Now()
+	if r.fn == nil || (!done && now.Sub(r.last) < downloadProgressInterval) {
+		return
+	}
+	r.last = now
+
+	// Compute throughput and ETA
+	if d := now.Sub(r.start).Seconds(); d > 0 {
+		r.p.Throughput = float64(r.n) / d
+	}
+	r.p.ETA = 0
+	if r.p.Throughput > 0 && r.p.Total > r.p.Downloaded {
+		r.p.ETA = time.Duration(float64(r.p.Total-r.p.Downloaded) / r.p.Throughput * float64(time.Second))
+	}
+	r.fn(r.p)
+}
+
+// downloadStatusError represents a download that failed because of its status code
+type downloadStatusError struct {
+	code int
+	src  string
+}
+
+// Error implements the error interface
+func (e downloadStatusError) Error() string {
+	return fmt.Sprintf("astibundler: getting %s returned %d status code", e.src, e.code)
+}
+
+// downloadNetworkError represents a download that failed because of the network
+type downloadNetworkError struct {
+	err error
+}
+
+// Error implements the error interface
+func (e downloadNetworkError) Error() string {
+	return e.err.Error()
+}
+
+// retryable checks whether a failed download should be retried. Only network errors and some status codes are,
+// local errors such as failing to write the file would fail again.
+func retryable(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case downloadNetworkError:
+		return true
+	case downloadStatusError:
+		switch e.code {
+		case http.StatusRequestTimeout, http.StatusRequestedRangeNotSatisfiable, http.StatusTooManyRequests:
+			return true
+		}
+		return e.code >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// downloadVendor downloads a vendor file into the cache. Data is written into a .part file which is resumed by the
+// next attempts, provided the remote file hasn't changed, and which is only moved to its final destination once
+// complete.
+func (b *Bundler) downloadVendor(ctx context.Context, src, dst string) (err error) {
+	// Total timeout
+	if b.download.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.download.timeout)
+		defer cancel()
+	}
+
+	// Make sure the destination dir exists
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		err = errors.Wrapf(err, "mkdirall %s failed", filepath.Dir(dst))
+		return
+	}
+
+	// Loop through attempts
+	var part = dst + ".part"
+	var delay = b.download.retryDelay
+	for attempt := 0; ; attempt++ {
+		// Download
+		if err = b.downloadVendorAttempt(ctx, src, part); err == nil {
+			break
+		}
+
+		// Check context error
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "downloading %s failed", src)
+			return
+		}
+
+		// No more retries
+		if attempt >= b.download.retries || !retryable(err) {
+			err = errors.Wrapf(err, "downloading %s failed after %d attempts", src, attempt+1)
+			return
+		}
+
+		// Wait
+		astilog.Infof("Downloading %s failed: %s, retrying in %s (%d/%d)", src, err, delay, attempt+1, b.download.retries)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "downloading %s failed", src)
+			return
+		}
+		if delay *= 2; delay > maxDownloadRetryDelay {
+			delay = maxDownloadRetryDelay
+		}
+	}
+
+	// Rename
+	if err = os.Rename(part, dst); err != nil {
+		err = errors.Wrapf(err, "renaming %s into %s failed", part, dst)
+		return
+	}
+	if err = removeAll(pathPartValidator(part)); err != nil {
+		return
+	}
+	return
+}
+
+// pathPartValidator returns the path of the file storing the validator (ETag or Last-Modified) of the remote file
+// a .part file has been downloaded from
+func pathPartValidator(part string) string {
+	return part + ".validator"
+}
+
+// responseValidator returns the validator of a response that can be used in an If-Range header, if any.
+// Weak ETags can't be used in an If-Range header.
+func responseValidator(resp *http.Response) string {
+	if e := resp.Header.Get("ETag"); len(e) > 0 && !strings.HasPrefix(e, "W/") {
+		return e
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// contentRangeStart returns the first byte position of a Content-Range header (e.g. "bytes 10-19/20")
+func contentRangeStart(h string) (o int64, err error) {
+	var s = strings.TrimPrefix(h, "bytes ")
+	var i = strings.Index(s, "-")
+	if s == h || i < 0 {
+		err = fmt.Errorf("astibundler: content range %q is invalid", h)
+		return
+	}
+	if o, err = strconv.ParseInt(s[:i], 10, 64); err != nil {
+		err = errors.Wrapf(err, "parsing content range %q failed", h)
+		return
+	}
+	return
+}
+
+// downloadVendorAttempt downloads a file into a .part file, resuming it if it already exists and the remote file
+// hasn't changed since
+func (b *Bundler) downloadVendorAttempt(ctx context.Context, src, part string) (err error) {
+	// Get offset, a .part file can only be resumed if the validator of its remote file is known
+	var offset int64
+	var validator string
+	if fi, errStat := os.Stat(part); errStat == nil {
+		if v, errRead := ioutil.ReadFile(pathPartValidator(part)); errRead == nil && len(v) > 0 {
+			offset = fi.Size()
+			validator = string(v)
+		}
+	}
+
+	// Abort the attempt if the response headers are not received in time
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var t = time.AfterFunc(b.download.connectTimeout, cancel)
+	defer t.Stop()
+
+	// Create request
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodGet, src, nil); err != nil {
+		err = errors.Wrapf(err, "creating request to %s failed", src)
+		return
+	}
+	req = req.WithContext(ctx)
+	if offset > 0 {
+		req.Header.Set("If-Range", validator)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	// Send request
+	var resp *http.Response
+	if resp, err = b.Client.Do(req); err != nil {
+		err = downloadNetworkError{err: errors.Wrapf(err, "getting %s failed", src)}
+		return
+	}
+	defer resp.Body.Close()
+
+	// Process status code
+	var flag = os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		// The range must start where the .part file ends, otherwise start over at the next attempt
+		var start int64
+		if start, err = contentRangeStart(resp.Header.Get("Content-Range")); err != nil || start != offset {
+			if err == nil {
+				err = fmt.Errorf("astibundler: content range of %s starts at %d whereas %d was expected", src, start, offset)
+			}
+			if errRemove := removeAll(part); errRemove != nil {
+				err = errRemove
+				return
+			}
+			err = downloadNetworkError{err: err}
+			return
+		}
+		astilog.Debugf("Resuming download of %s at %s", src, humanBytes(offset))
+		flag |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The .part file doesn't match the remote file, start over at the next attempt
+		if err = removeAll(part); err != nil {
+			return
+		}
+		err = downloadStatusError{code: resp.StatusCode, src: src}
+		return
+	case resp.StatusCode >= http.StatusOK && resp.StatusCode <= 299:
+		// Whole file, for instance when the server doesn't support ranges or when the remote file has changed
+		flag |= os.O_TRUNC
+
+		// Store the validator of the remote file so that the .part file can be resumed
+		if err = ioutil.WriteFile(pathPartValidator(part), []byte(responseValidator(resp)), 0666); err != nil {
+			err = errors.Wrapf(err, "writing %s failed", pathPartValidator(part))
+			return
+		}
+	default:
+		err = downloadStatusError{code: resp.StatusCode, src: src}
+		return
+	}
+
+	// Open destination
+	var f *os.File
+	if f, err = os.OpenFile(part, flag, 0666); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", part)
+		return
+	}
+	defer f.Close()
+
+	// Get total
+	var total int64 = -1
+	if resp.ContentLength >= 0 {
+		total = resp.ContentLength
+		if flag&os.O_APPEND != 0 {
+			total += offset
+		}
+	}
+	if flag&os.O_APPEND == 0 {
+		offset = 0
+	}
+	var pr = newDownloadProgressReporter(b.OnDownloadProgress, src, strings.TrimSuffix(part, ".part"), offset, total)
+
+	// Copy, aborting the attempt if no data is received in time
+	t.Reset(b.download.idleTimeout)
+	var n int64
+	var buf = make([]byte, 32*1024)
+	for {
+		var r int
+		r, err = resp.Body.Read(buf)
+		if r > 0 {
+			t.Reset(b.download.idleTimeout)
+			if _, errWrite := f.Write(buf[:r]); errWrite != nil {
+				err = errors.Wrapf(errWrite, "writing to %s failed", part)
+				return
+			}
+			n += int64(r)
+			pr.add(int64(r), false)
+		}
+		if err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			err = downloadNetworkError{err: errors.Wrapf(err, "reading body of %s failed", src)}
+			return
+		}
+	}
+
+	// Check size
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = downloadNetworkError{err: fmt.Errorf("astibundler: received %d bytes of %s whereas %d were expected", n, src, resp.ContentLength)}
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s failed", part)
+		return
+	}
+	pr.add(0, true)
+	return
+}
@@ -0,0 +1,278 @@
+package astibundler
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func newFetchBundler() *Bundler {
+	return &Bundler{
+		Client: &http.Client{},
+		download: downloadOptions{
+			connectTimeout: 5 * time.Second,
+			idleTimeout:    5 * time.Second,
+		},
+	}
+}
+
+func TestDownloadVendorAttempt(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	for _, c := range []struct {
+		name              string
+		part              string
+		validator         string
+		handler           func(w http.ResponseWriter, r *http.Request)
+		expected          string
+		expectedErr       bool
+		expectedValidator string
+		partRemoved       bool
+		statusCode        int
+		retryable         bool
+	}{
+		{
+			name: "200",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, content)
+			},
+			expected: content,
+		},
+		{
+			name: "200 stores validator",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, content)
+			},
+			expected:          content,
+			expectedValidator: `"v1"`,
+		},
+		{
+			name:      "200 after range",
+			part:      "0123",
+			validator: `"v1"`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				// Server ignoring ranges
+				fmt.Fprint(w, content)
+			},
+			expected: content,
+		},
+		{
+			name: "200 without validator",
+			part: "0123",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if len(r.Header.Get("Range")) > 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				fmt.Fprint(w, content)
+			},
+			expected: content,
+		},
+		{
+			name:      "206",
+			part:      "0123456789",
+			validator: `"v1"`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "bytes=10-" || r.Header.Get("If-Range") != `"v1"` {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+				w.WriteHeader(http.StatusPartialContent)
+				fmt.Fprint(w, content[10:])
+			},
+			expected:          content,
+			expectedValidator: `"v1"`,
+		},
+		{
+			name:      "206 with changed remote file",
+			part:      "9876543210",
+			validator: `"v0"`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				// Server honoring If-Range
+				w.Header().Set("ETag", `"v1"`)
+				if r.Header.Get("If-Range") != `"v1"` {
+					fmt.Fprint(w, content)
+					return
+				}
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			expected:          content,
+			expectedValidator: `"v1"`,
+		},
+		{
+			name:      "206 at wrong offset",
+			part:      "0123456789",
+			validator: `"v1"`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 5-%d/%d", len(content)-1, len(content)))
+				w.WriteHeader(http.StatusPartialContent)
+				fmt.Fprint(w, content[5:])
+			},
+			expectedErr: true,
+			partRemoved: true,
+			retryable:   true,
+		},
+		{
+			name:      "416",
+			part:      "0123456789abcdefghijklmnop",
+			validator: `"v1"`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			},
+			expectedErr: true,
+			partRemoved: true,
+			retryable:   true,
+			statusCode:  http.StatusRequestedRangeNotSatisfiable,
+		},
+		{
+			name: "404",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expectedErr: true,
+			statusCode:  http.StatusNotFound,
+		},
+		{
+			name: "short body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				fmt.Fprint(w, content[:5])
+			},
+			expectedErr: true,
+			retryable:   true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			// Serve
+			var s = httptest.NewServer(http.HandlerFunc(c.handler))
+			defer s.Close()
+
+			// Create part
+			var dir, err = ioutil.TempDir("", "astibundler")
+			if err != nil {
+				t.Fatalf("creating temp dir failed: %s", err)
+			}
+			defer os.RemoveAll(dir)
+			var part = filepath.Join(dir, "electron.zip.part")
+			if len(c.part) > 0 {
+				if err = ioutil.WriteFile(part, []byte(c.part), 0666); err != nil {
+					t.Fatalf("writing %s failed: %s", part, err)
+				}
+			}
+			if len(c.validator) > 0 {
+				if err = ioutil.WriteFile(pathPartValidator(part), []byte(c.validator), 0666); err != nil {
+					t.Fatalf("writing %s failed: %s", pathPartValidator(part), err)
+				}
+			}
+
+			// Download
+			err = newFetchBundler().downloadVendorAttempt(context.Background(), s.URL, part)
+			if c.expectedErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				if r := retryable(err); r != c.retryable {
+					t.Errorf("expected retryable %v, got %v for %s", c.retryable, r, err)
+				}
+				if c.statusCode > 0 {
+					if e, ok := errors.Cause(err).(downloadStatusError); !ok || e.code != c.statusCode {
+						t.Errorf("expected status code %d, got %s", c.statusCode, err)
+					}
+				}
+				if c.partRemoved {
+					if _, errStat := os.Stat(part); !os.IsNotExist(errStat) {
+						t.Errorf("expected %s to be removed", part)
+					}
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			// Check content
+			var b []byte
+			if b, err = ioutil.ReadFile(part); err != nil {
+				t.Fatalf("reading %s failed: %s", part, err)
+			}
+			if string(b) != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, b)
+			}
+			if len(c.expectedValidator) > 0 {
+				if b, err = ioutil.ReadFile(pathPartValidator(part)); err != nil {
+					t.Errorf("reading %s failed: %s", pathPartValidator(part), err)
+				} else if string(b) != c.expectedValidator {
+					t.Errorf("expected validator %q, got %q", c.expectedValidator, b)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadVendor(t *testing.T) {
+	// Serve a file failing once
+	const content = "0123456789"
+	var attempts int
+	var s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer s.Close()
+
+	// Download into a dir that doesn't exist yet
+	var dir, err = ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatalf("creating temp dir failed: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	var dst = filepath.Join(dir, "cache", "electron.zip")
+	var b = newFetchBundler()
+	b.download.retries = 1
+	b.download.retryDelay = time.Millisecond
+	if err = b.downloadVendor(context.Background(), s.URL, dst); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	// Check
+	var c []byte
+	if c, err = ioutil.ReadFile(dst); err != nil {
+		t.Fatalf("reading %s failed: %s", dst, err)
+	}
+	if string(c) != content {
+		t.Errorf("expected %q, got %q", content, c)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	for _, p := range []string{dst + ".part", pathPartValidator(dst + ".part")} {
+		if _, errStat := os.Stat(p); !os.IsNotExist(errStat) {
+			t.Errorf("expected %s to be removed", p)
+		}
+	}
+
+	// Client errors are not retried
+	attempts = 0
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusForbidden)
+	})
+	if err = b.downloadVendor(context.Background(), s.URL, dst+".2"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected a 403 error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
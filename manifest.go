@@ -0,0 +1,271 @@
+package astibundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// manifestName is the name of the file, in the output path, storing the fingerprints of the bundled environments
+const manifestName = ".astibundler.json"
+
+// regexpBindData matches the files and directories generated by BindData
+var regexpBindData = regexp.MustCompile(`^bind_[a-z0-9_]+(\.go)?$`)
+
+// regexpWindowsSyso matches the .syso files generated by the bundler
+var regexpWindowsSyso = regexp.MustCompile(`^rsrc_windows_[a-z0-9]+\.syso$`)
+
+// sourceExtensions are the extensions of the files considered as go sources
+var sourceExtensions = map[string]bool{
+	".c":    true,
+	".go":   true,
+	".h":    true,
+	".mod":  true,
+	".s":    true,
+	".sum":  true,
+	".syso": true,
+}
+
+// manifest represents the fingerprints of the bundled environments indexed by environment name
+type manifest map[string]string
+
+// readManifest reads the manifest
+func (b *Bundler) readManifest() (m manifest, err error) {
+	m = make(manifest)
+	var p = filepath.Join(b.pathOutput, manifestName)
+	var c []byte
+	if c, err = ioutil.ReadFile(p); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = errors.Wrapf(err, "reading %s failed", p)
+		return
+	}
+	if err = json.Unmarshal(c, &m); err != nil {
+		// A corrupted manifest only means environments will be bundled again
+		astilog.Debugf("Unmarshaling %s failed: %s, ignoring it", p, err)
+		err = nil
+		m = make(manifest)
+	}
+	return
+}
+
+// writeManifest writes the manifest
+func (b *Bundler) writeManifest(m manifest) (err error) {
+	var p = filepath.Join(b.pathOutput, manifestName)
+	var c []byte
+	if c, err = json.MarshalIndent(m, "", "  "); err != nil {
+		err = errors.Wrap(err, "marshaling manifest failed")
+		return
+	}
+	if err = ioutil.WriteFile(p, c, 0666); err != nil {
+		err = errors.Wrapf(err, "writing %s failed", p)
+		return
+	}
+	return
+}
+
+// configurationHash hashes the parts of the configuration shared by all environments
+func configurationHash(c Configuration) (o string, err error) {
+	c.Environments = nil
+	c.EnvironmentFilter = ""
+	c.Force = false
+	c.Download = ConfigurationDownload{}
+	c.VendorDownloadConcurrency = 0
+	var b []byte
+	if b, err = json.Marshal(c); err != nil {
+		err = errors.Wrap(err, "marshaling configuration failed")
+		return
+	}
+	var h = sha256.Sum256(b)
+	o = hex.EncodeToString(h[:])
+	return
+}
+
+// fingerprintEnv returns the part of the go build environment of an environment that is fingerprinted. When the whole
+// environment is passed through, only the variables that go build depends on and the ones set by the environment are
+// kept since the others, such as SHLVL or OLDPWD, change between shells.
+func (b *Bundler) fingerprintEnv(e ConfigurationEnvironment) (o []string) {
+	// Get environment
+	var env = b.buildEnv(e)
+	if !b.buildEnvAll {
+		return env
+	}
+
+	// Get keys
+	var ks = append([]string{}, buildEnvKeys...)
+	for _, kv := range e.Env {
+		ks = append(ks, strings.SplitN(kv, "=", 2)[0])
+	}
+
+	// Filter
+	for _, kv := range env {
+		var k = strings.SplitN(kv, "=", 2)[0]
+		if strings.HasPrefix(strings.ToUpper(k), "GO") || strings.HasPrefix(strings.ToUpper(k), "CGO_") {
+			o = append(o, kv)
+			continue
+		}
+		for _, fk := range ks {
+			if fk == k || (runtime.GOOS == "windows" && strings.EqualFold(fk, k)) {
+				o = append(o, kv)
+				break
+			}
+		}
+	}
+	sort.Strings(o)
+	return
+}
+
+// fingerprint computes the fingerprint of the inputs of an environment
+func (b *Bundler) fingerprint(e ConfigurationEnvironment) (o string, err error) {
+	var h = sha256.New()
+
+	// Configuration
+	var c []byte
+	if c, err = json.Marshal(e); err != nil {
+		err = errors.Wrap(err, "marshaling environment failed")
+		return
+	}
+	fmt.Fprintf(h, "configuration %s %s\n", b.configurationHash, c)
+
+	// Toolchain
+	fmt.Fprintf(h, "toolchain %s\n", b.goVersion)
+
+	// Build environment
+	fmt.Fprintf(h, "env %s\n", strings.Join(b.fingerprintEnv(e), "\n"))
+
+	// Go sources
+	var pathSources = b.pathModule
+	if len(pathSources) == 0 {
+		pathSources = b.pathInput
+	}
+	if err = b.fingerprintDir(h, "sources", pathSources, func(path string) bool {
+		if regexpWindowsSyso.MatchString(filepath.Base(path)) ||
+			(filepath.Dir(path) == b.pathBindOutput && regexpBindData.MatchString(filepath.Base(path))) {
+			return false
+		}
+		return sourceExtensions[filepath.Ext(path)]
+	}); err != nil {
+		err = errors.Wrapf(err, "fingerprinting go sources in %s failed", pathSources)
+		return
+	}
+
+	// Resources
+	var as []asset
+	if as, err = b.resourceAssets(e.OS, e.Arch); err != nil {
+		err = errors.Wrap(err, "building resource assets failed")
+		return
+	}
+	for _, a := range as {
+		if err = fingerprintFile(h, a.name, a.path); err != nil {
+			err = errors.Wrapf(err, "fingerprinting %s failed", a.path)
+			return
+		}
+	}
+
+	// Icons
+	for _, p := range []string{b.pathIconDarwin, b.pathIconLinux, b.pathIconWindows} {
+		if err = fingerprintFile(h, "icon", p); err != nil {
+			err = errors.Wrapf(err, "fingerprinting icon %s failed", p)
+			return
+		}
+	}
+
+	// Vendor zips
+	if len(b.pathAstilectron) > 0 {
+		if err = b.fingerprintDir(h, "astilectron", b.pathAstilectron, nil); err != nil {
+			err = errors.Wrapf(err, "fingerprinting astilectron in %s failed", b.pathAstilectron)
+			return
+		}
+	} else if err = fingerprintFile(h, "vendor", b.pathCacheAstilectron()); err != nil {
+		err = errors.Wrapf(err, "fingerprinting %s failed", b.pathCacheAstilectron())
+		return
+	}
+	if err = fingerprintFile(h, "vendor", b.pathElectron(e.OS, e.Arch)); err != nil {
+		err = errors.Wrapf(err, "fingerprinting %s failed", b.pathElectron(e.OS, e.Arch))
+		return
+	}
+	o = hex.EncodeToString(h.Sum(nil))
+	return
+}
+
+// fingerprintDir adds the files of a dir accepted by the filter to the fingerprint.
+// Hidden folders, node_modules and the folders written by the bundler are skipped.
+func (b *Bundler) fingerprintDir(h hash.Hash, kind, dir string, filter func(path string) bool) (err error) {
+	var ps []string
+	if err = filepath.Walk(dir, func(path string, info os.FileInfo, e error) error {
+		if e != nil {
+			if os.IsNotExist(e) && path == dir {
+				return nil
+			}
+			return e
+		}
+		if info.IsDir() {
+			if path != dir && (strings.HasPrefix(info.Name(), ".") || info.Name() == "node_modules" ||
+				path == b.pathOutput || path == b.pathCache ||
+				(filepath.Dir(path) == b.pathBindOutput && regexpBindData.MatchString(info.Name()))) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filter == nil || filter(path) {
+			ps = append(ps, path)
+		}
+		return nil
+	}); err != nil {
+		err = errors.Wrapf(err, "walking through %s failed", dir)
+		return
+	}
+	sort.Strings(ps)
+	for _, p := range ps {
+		var r string
+		if r, err = filepath.Rel(dir, p); err != nil {
+			err = errors.Wrapf(err, "filepath.Rel of %s and %s failed", dir, p)
+			return
+		}
+		if err = fingerprintFile(h, kind+" "+filepath.ToSlash(r), p); err != nil {
+			err = errors.Wrapf(err, "fingerprinting %s failed", p)
+			return
+		}
+	}
+	return
+}
+
+// fingerprintFile adds a file to the fingerprint. Missing files are fingerprinted as such.
+func fingerprintFile(h hash.Hash, kind, path string) (err error) {
+	if len(path) == 0 {
+		return
+	}
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "%s missing\n", kind)
+			err = nil
+			return
+		}
+		err = errors.Wrapf(err, "opening %s failed", path)
+		return
+	}
+	defer f.Close()
+	var fh = sha256.New()
+	if _, err = io.Copy(fh, f); err != nil {
+		err = errors.Wrapf(err, "hashing %s failed", path)
+		return
+	}
+	fmt.Fprintf(h, "%s %x\n", kind, fh.Sum(nil))
+	return
+}
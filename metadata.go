@@ -0,0 +1,98 @@
+package astibundler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/pkg/errors"
+)
+
+// Constants
+const (
+	metadataName      = "metadata.json"
+	modulePathBundler = "github.com/asticode/go-astilectron-bundler"
+)
+
+// Metadata represents the metadata bound by the bundler
+type Metadata struct {
+	Arch               string            `json:"arch"`
+	AstilectronVersion string            `json:"astilectron_version"`
+	BuiltAt            time.Time         `json:"built_at"`
+	BundlerVersion     string            `json:"bundler_version"`
+	ElectronVersion    string            `json:"electron_version"`
+	Hashes             map[string]string `json:"hashes"` // Hex encoded SHA-256 of the assets indexed by name
+	OS                 string            `json:"os"`
+	ResourcesCount     int               `json:"resources_count"`
+	Tags               string            `json:"tags"`
+	VendorMode         string            `json:"vendor_mode"`
+}
+
+// bundlerVersion returns the version of the bundler module the binary has been built with
+func bundlerVersion() string {
+	if i, ok := debug.ReadBuildInfo(); ok {
+		if i.Main.Path == modulePathBundler {
+			return i.Main.Version
+		}
+		for _, d := range i.Deps {
+			if d.Path == modulePathBundler {
+				if d.Replace != nil {
+					return d.Replace.Version
+				}
+				return d.Version
+			}
+		}
+	}
+	return "unknown"
+}
+
+// pathCacheMetadata returns the path of the metadata file generated for an environment. It's stored in the cache
+// since dev mode reads it from disk at runtime.
+func (b *Bundler) pathCacheMetadata(oS, arch string) string {
+	return filepath.Join(b.pathCache, fmt.Sprintf("metadata-%s-%s-%s.json", b.configurationHash[:16], oS, arch))
+}
+
+// writeMetadata writes the metadata of an environment and returns the asset it's bound as
+func (b *Bundler) writeMetadata(oS, arch, tags string, resourcesCount int, ds []assetDigest) (a asset, err error) {
+	// Build metadata
+	var m = Metadata{
+		Arch:               arch,
+		AstilectronVersion: astilectron.VersionAstilectron,
+		BuiltAt:            time.Now().UTC(),
+		BundlerVersion:     bundlerVersion(),
+		ElectronVersion:    astilectron.VersionElectron,
+		Hashes:             make(map[string]string),
+		OS:                 oS,
+		ResourcesCount:     resourcesCount,
+		Tags:               tags,
+		VendorMode:         b.vendorMode,
+	}
+	for _, d := range ds {
+		m.Hashes[d.Name] = d.Hex
+	}
+
+	// Marshal
+	var bs []byte
+	if bs, err = json.MarshalIndent(m, "", "  "); err != nil {
+		err = errors.Wrap(err, "marshaling metadata failed")
+		return
+	}
+
+	// Write
+	a = asset{name: path.Join(b.vendorNames.Prefix, metadataName), path: b.pathCacheMetadata(oS, arch), vendor: true}
+	if err = os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		err = errors.Wrapf(err, "mkdirall %s failed", filepath.Dir(a.path))
+		return
+	}
+	if err = ioutil.WriteFile(a.path, bs, 0644); err != nil {
+		err = errors.Wrapf(err, "writing %s failed", a.path)
+		return
+	}
+	return
+}
@@ -0,0 +1,191 @@
+package astibundler
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// payloadMagic ends the payload appended to executables.
+// Layout of the payload: [files][json index][index length as uint64 little endian][magic]
+const payloadMagic = "astipayl"
+
+// payloadTrailerSize is the size of the index length and the magic
+const payloadTrailerSize = 8 + len(payloadMagic)
+
+// ErrPayloadNotFound is returned when the executable has no payload or the payload has no such file
+var ErrPayloadNotFound = errors.New("astibundler: payload not found")
+
+// payloadFile represents the location of a file in the payload
+type payloadFile struct {
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+}
+
+// payloadIndex indexes the payload files by name
+type payloadIndex map[string]payloadFile
+
+// appendPayload appends assets to a binary
+func (b *Bundler) appendPayload(binaryPath string, as []asset) (err error) {
+	// Open binary
+	var f *os.File
+	if f, err = os.OpenFile(binaryPath, os.O_WRONLY|os.O_APPEND, 0); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", binaryPath)
+		return
+	}
+	defer f.Close()
+
+	// Get offset
+	var fi os.FileInfo
+	if fi, err = f.Stat(); err != nil {
+		err = errors.Wrapf(err, "stating %s failed", binaryPath)
+		return
+	}
+	var offset = fi.Size()
+
+	// Loop through assets
+	var idx = make(payloadIndex)
+	for _, a := range as {
+		if err = func() (err error) {
+			// Open asset
+			var af *os.File
+			if af, err = os.Open(a.path); err != nil {
+				err = errors.Wrapf(err, "opening %s failed", a.path)
+				return
+			}
+			defer af.Close()
+
+			// Append
+			astilog.Debugf("Appending %s to %s", a.path, binaryPath)
+			var n int64
+			if n, err = io.Copy(f, af); err != nil {
+				err = errors.Wrapf(err, "appending %s to %s failed", a.path, binaryPath)
+				return
+			}
+			idx[a.name] = payloadFile{Offset: offset, Size: n}
+			offset += n
+			return
+		}(); err != nil {
+			return
+		}
+
+		// Check context error
+		if b.ctx.Err() != nil {
+			return b.ctx.Err()
+		}
+	}
+
+	// Append index
+	var bi []byte
+	if bi, err = json.Marshal(idx); err != nil {
+		err = errors.Wrap(err, "marshaling payload index failed")
+		return
+	}
+	var t = make([]byte, payloadTrailerSize)
+	binary.LittleEndian.PutUint64(t, uint64(len(bi)))
+	copy(t[8:], payloadMagic)
+	if _, err = f.Write(append(bi, t...)); err != nil {
+		err = errors.Wrapf(err, "appending payload index to %s failed", binaryPath)
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s failed", binaryPath)
+		return
+	}
+	return
+}
+
+// readPayload reads a file from the payload appended to an executable
+func readPayload(path, name string) (o []byte, err error) {
+	// Open executable
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", path)
+		return
+	}
+	defer f.Close()
+
+	// Read trailer
+	var fi os.FileInfo
+	if fi, err = f.Stat(); err != nil {
+		err = errors.Wrapf(err, "stating %s failed", path)
+		return
+	}
+	var t = make([]byte, payloadTrailerSize)
+	if fi.Size() < int64(len(t)) {
+		err = ErrPayloadNotFound
+		return
+	}
+	if _, err = f.ReadAt(t, fi.Size()-int64(len(t))); err != nil {
+		err = errors.Wrapf(err, "reading payload trailer of %s failed", path)
+		return
+	}
+	if string(t[8:]) != payloadMagic {
+		err = ErrPayloadNotFound
+		return
+	}
+
+	// Read index
+	var l = int64(binary.LittleEndian.Uint64(t))
+	if l < 0 || l > fi.Size()-int64(len(t)) {
+		err = fmt.Errorf("astibundler: payload index length %d of %s is invalid", l, path)
+		return
+	}
+	var bi = make([]byte, l)
+	if _, err = f.ReadAt(bi, fi.Size()-int64(len(t))-l); err != nil {
+		err = errors.Wrapf(err, "reading payload index of %s failed", path)
+		return
+	}
+	var idx payloadIndex
+	if err = json.Unmarshal(bi, &idx); err != nil {
+		err = errors.Wrapf(err, "unmarshaling payload index of %s failed", path)
+		return
+	}
+
+	// Read file
+	var pf, ok = idx[name]
+	if !ok {
+		err = ErrPayloadNotFound
+		return
+	} else if end := fi.Size() - int64(len(t)) - l; pf.Offset < 0 || pf.Size < 0 || pf.Offset > end || pf.Size > end-pf.Offset {
+		err = fmt.Errorf("astibundler: location of %s in payload of %s is invalid", name, path)
+		return
+	}
+	o = make([]byte, pf.Size)
+	if _, err = f.ReadAt(o, pf.Offset); err != nil {
+		err = errors.Wrapf(err, "reading %s in payload of %s failed", name, path)
+		return
+	}
+	return
+}
+
+// PayloadDisembedder returns a disembed function reading files from the payload appended to the executable.
+// If the executable has no payload, for instance when using "go run", the fallback disembed function is used.
+func PayloadDisembedder(fallback func(string) ([]byte, error)) func(string) ([]byte, error) {
+	return func(name string) (o []byte, err error) {
+		// Get executable path
+		var p string
+		if p, err = os.Executable(); err != nil {
+			err = errors.Wrap(err, "getting executable path failed")
+			return
+		}
+
+		// Read payload
+		if o, err = readPayload(p, name); err == ErrPayloadNotFound && fallback != nil {
+			return fallback(name)
+		}
+		return
+	}
+}
+
+// NewPayloadProvisioner builds a provisioner reading the vendor zips from the payload appended to the executable
+// by the bundler. The fallback disembed function (e.g. Asset) is used when the executable has no payload.
+func NewPayloadProvisioner(fallback func(string) ([]byte, error)) astilectron.Provisioner {
+	return NewProvisioner(PayloadDisembedder(fallback))
+}
@@ -0,0 +1,127 @@
+package astibundler
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatalf("writing %s failed: %s", path, err)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	// Create files
+	var dir, err = ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatalf("creating temp dir failed: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	var bp = filepath.Join(dir, "binary")
+	writeTestFile(t, bp, "binary")
+	var as []asset
+	for n, c := range map[string]string{
+		"vendor/astilectron.zip": "astilectron",
+		"vendor/electron.zip":    "electron",
+		"vendor/empty.zip":       "",
+	} {
+		var p = filepath.Join(dir, filepath.Base(n))
+		writeTestFile(t, p, c)
+		as = append(as, asset{name: n, path: p})
+	}
+
+	// Append
+	var b = &Bundler{ctx: context.Background()}
+	if err = b.appendPayload(bp, as); err != nil {
+		t.Fatalf("appending payload failed: %s", err)
+	}
+
+	// Read
+	for n, e := range map[string]string{
+		"vendor/astilectron.zip": "astilectron",
+		"vendor/electron.zip":    "electron",
+		"vendor/empty.zip":       "",
+	} {
+		var o []byte
+		if o, err = readPayload(bp, n); err != nil {
+			t.Errorf("reading %s failed: %s", n, err)
+		} else if string(o) != e {
+			t.Errorf("expected %q for %s, got %q", e, n, o)
+		}
+	}
+	if _, err = readPayload(bp, "vendor/unknown.zip"); err != ErrPayloadNotFound {
+		t.Errorf("expected ErrPayloadNotFound for unknown file, got %v", err)
+	}
+
+	// The binary is left untouched
+	var c []byte
+	if c, err = ioutil.ReadFile(bp); err != nil {
+		t.Fatalf("reading %s failed: %s", bp, err)
+	}
+	if string(c[:6]) != "binary" {
+		t.Errorf("expected binary to start with %q, got %q", "binary", c[:6])
+	}
+}
+
+func TestReadPayloadMalformed(t *testing.T) {
+	var dir, err = ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatalf("creating temp dir failed: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	var trailer = func(l int) string {
+		var t = make([]byte, payloadTrailerSize)
+		binary.LittleEndian.PutUint64(t, uint64(l))
+		copy(t[8:], payloadMagic)
+		return string(t)
+	}
+	for _, c := range []struct {
+		name     string
+		content  string
+		notFound bool
+	}{
+		{name: "empty", notFound: true},
+		{name: "no magic", content: "a binary without payload", notFound: true},
+		{name: "truncated magic", content: payloadMagic[2:], notFound: true},
+		{name: "invalid index length", content: "{}" + trailer(1000)},
+		{name: "invalid index", content: "{notjson" + trailer(8)},
+		{name: "invalid location", content: `{"f":{"offset":0,"size":1000}}` + trailer(30)},
+		{name: "negative size", content: `{"f":{"offset":0,"size":-1}}` + trailer(28)},
+		{name: "negative index length", content: trailer(-1)},
+		{name: "overflowing location", content: `{"f":{"offset":9223372036854775807,"size":9223372036854775807}}` + trailer(63)},
+		{name: "overflowing size", content: `{"f":{"offset":1,"size":9223372036854775807}}` + trailer(45)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var p = filepath.Join(dir, "binary")
+			writeTestFile(t, p, c.content)
+			_, err := readPayload(p, "f")
+			if c.notFound && err != ErrPayloadNotFound {
+				t.Errorf("expected ErrPayloadNotFound, got %v", err)
+			} else if !c.notFound && (err == nil || err == ErrPayloadNotFound) {
+				t.Errorf("expected a malformed payload error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPayloadDisembedder(t *testing.T) {
+	// The test binary has no payload
+	var d = PayloadDisembedder(func(name string) ([]byte, error) {
+		return []byte("fallback " + name), nil
+	})
+	if o, err := d("vendor/electron.zip"); err != nil {
+		t.Errorf("disembedding failed: %s", err)
+	} else if string(o) != "fallback vendor/electron.zip" {
+		t.Errorf("expected fallback content, got %q", o)
+	}
+
+	// No fallback
+	if _, err := PayloadDisembedder(nil)("vendor/electron.zip"); err != ErrPayloadNotFound {
+		t.Errorf("expected ErrPayloadNotFound, got %v", err)
+	}
+}
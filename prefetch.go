@@ -0,0 +1,139 @@
+package astibundler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// Default number of vendor files downloaded concurrently
+const defaultVendorDownloadConcurrency = 4
+
+// vendorPrefetch represents a vendor file downloaded in the background
+type vendorPrefetch struct {
+	done chan struct{}
+	err  error
+	src  string
+}
+
+// prefetchFiles returns the vendor files needed by the environments which are missing from the cache, indexed by
+// cache path, in the order they should be downloaded
+func (b *Bundler) prefetchFiles(es []ConfigurationEnvironment) (dsts []string, srcs map[string]string) {
+	srcs = make(map[string]string)
+	var add = func(src, dst string) {
+		if _, ok := srcs[dst]; ok {
+			return
+		} else if _, errStat := os.Stat(dst); errStat == nil {
+			return
+		}
+		srcs[dst] = src
+		dsts = append(dsts, dst)
+	}
+	if len(es) > 0 && len(b.pathAstilectron) == 0 {
+		add(astilectron.AstilectronDownloadSrc(), b.pathCacheAstilectron())
+	}
+	for _, e := range es {
+		add(b.electronSrc(e.OS, e.Arch), b.pathCacheElectron(e.OS, e.Arch))
+	}
+	return
+}
+
+// prefetchVendor downloads the vendor files needed by the environments concurrently in the background. Downloads
+// are stopped once the context is cancelled, and the returned wait group is done once all of them have returned.
+func (b *Bundler) prefetchVendor(ctx context.Context, es []ConfigurationEnvironment) *sync.WaitGroup {
+	// Get files
+	var dsts, srcs = b.prefetchFiles(es)
+	var wg = &sync.WaitGroup{}
+
+	// Register prefetches before starting them so that provisioning waits for them
+	b.prefetchesMutex.Lock()
+	b.prefetches = make(map[string]*vendorPrefetch)
+	for _, dst := range dsts {
+		b.prefetches[dst] = &vendorPrefetch{done: make(chan struct{}), src: srcs[dst]}
+	}
+	b.prefetchesMutex.Unlock()
+	if len(dsts) == 0 {
+		return wg
+	}
+
+	// Loop through files
+	astilog.Infof("Prefetching %d vendor files with %d concurrent downloads", len(dsts), b.vendorDownloadConcurrency)
+	var sem = make(chan struct{}, b.vendorDownloadConcurrency)
+	for idx, dst := range dsts {
+		wg.Add(1)
+		go func(idx int, dst string, p *vendorPrefetch) {
+			defer wg.Done()
+			defer close(p.done)
+
+			// Acquire slot
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				p.err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			// Download
+			// Progress is reported by the callback instead of per file logs when it's set
+			var n = time.Now()
+			if b.OnDownloadProgress == nil {
+				astilog.Infof("Downloading %s (%d/%d)", p.src, idx+1, len(dsts))
+			}
+			if p.err = b.downloadVendor(ctx, p.src, dst); p.err != nil {
+				astilog.Errorf("Downloading %s failed: %s", p.src, p.err)
+				return
+			} else if b.OnDownloadProgress != nil {
+				return
+			}
+			var size string
+			if fi, errStat := os.Stat(dst); errStat == nil {
+				size = humanBytes(fi.Size()) + " "
+			}
+			astilog.Infof("Downloaded %s (%d/%d): %sin %s", p.src, idx+1, len(dsts), size, time.Since(n).Round(time.Millisecond))
+		}(idx, dst, b.prefetches[dst])
+	}
+	return wg
+}
+
+// waitPrefetch waits for the prefetch of a vendor file, if any
+func (b *Bundler) waitPrefetch(dst string) (err error) {
+	// Get prefetch
+	b.prefetchesMutex.Lock()
+	p, ok := b.prefetches[dst]
+	b.prefetchesMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	// Wait
+	select {
+	case <-p.done:
+	case <-b.ctx.Done():
+		return b.ctx.Err()
+	}
+	if p.err != nil {
+		err = errors.Wrapf(p.err, "prefetching %s failed", p.src)
+		return
+	}
+	return
+}
+
+// validateVendorDownloadConcurrency checks that the number of concurrent downloads is valid
+func validateVendorDownloadConcurrency(c int) (o int, err error) {
+	if c < 0 {
+		err = fmt.Errorf("vendor download concurrency %d is invalid", c)
+		return
+	} else if c == 0 {
+		o = defaultVendorDownloadConcurrency
+		return
+	}
+	o = c
+	return
+}
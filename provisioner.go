@@ -1,14 +1,177 @@
 package astibundler
 
-import "github.com/asticode/go-astilectron"
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/pkg/errors"
+)
 
 // Constants
 const (
+	vendorPrefix       = "vendor"
 	zipNameAstilectron = "astilectron.zip"
 	zipNameElectron    = "electron.zip"
 )
 
+// Vendor modes
+const (
+	vendorModeBind     = "bind"
+	vendorModeDownload = "download"
+	vendorModePayload  = "payload"
+)
+
 // NewProvisioner builds the proper disembedder provisioner
 func NewProvisioner(disembedFunc func(string) ([]byte, error)) astilectron.Provisioner {
-	return astilectron.NewDisembedderProvisioner(disembedFunc, "vendor/"+zipNameAstilectron, "vendor/"+zipNameElectron)
+	return NewProvisionerWithOptions(ProvisionerOptions{DisembedFunc: disembedFunc})
+}
+
+// ProvisionerOptions represents provisioner options. They must match the "vendor_assets" configuration of the
+// bundler.
+type ProvisionerOptions struct {
+	AstilectronDisembedFunc func(string) ([]byte, error) // Defaults to DisembedFunc
+	AstilectronName         string                       // Defaults to "astilectron.zip"
+	DisembedFunc            func(string) ([]byte, error)
+	ElectronDisembedFunc    func(string) ([]byte, error) // Defaults to DisembedFunc
+	ElectronName            string                       // Defaults to "electron.zip"
+	Prefix                  string                       // Defaults to "vendor"
+}
+
+// defaults returns the options with default values set
+func (o ProvisionerOptions) defaults() ProvisionerOptions {
+	if len(o.AstilectronName) == 0 {
+		o.AstilectronName = zipNameAstilectron
+	}
+	if len(o.ElectronName) == 0 {
+		o.ElectronName = zipNameElectron
+	}
+	if len(o.Prefix) == 0 {
+		o.Prefix = vendorPrefix
+	}
+	if o.AstilectronDisembedFunc == nil {
+		o.AstilectronDisembedFunc = o.DisembedFunc
+	}
+	if o.ElectronDisembedFunc == nil {
+		o.ElectronDisembedFunc = o.DisembedFunc
+	}
+	return o
+}
+
+// NewProvisionerWithOptions builds a disembedder provisioner based on options
+func NewProvisionerWithOptions(o ProvisionerOptions) astilectron.Provisioner {
+	o = o.defaults()
+	var a, e = path.Join(o.Prefix, o.AstilectronName), path.Join(o.Prefix, o.ElectronName)
+	return astilectron.NewDisembedderProvisioner(func(name string) ([]byte, error) {
+		switch name {
+		case a:
+			return o.AstilectronDisembedFunc(name)
+		case e:
+			return o.ElectronDisembedFunc(name)
+		}
+		return o.DisembedFunc(name)
+	}, a, e)
+}
+
+// ReadMetadata reads the metadata bound by the bundler
+func ReadMetadata(disembedFunc func(string) ([]byte, error)) (Metadata, error) {
+	return ReadMetadataWithOptions(ProvisionerOptions{DisembedFunc: disembedFunc})
+}
+
+// ReadMetadataWithOptions reads the metadata bound by the bundler under the prefix of the options
+func ReadMetadataWithOptions(o ProvisionerOptions) (m Metadata, err error) {
+	// Disembed
+	o = o.defaults()
+	var n = path.Join(o.Prefix, metadataName)
+	var b []byte
+	if b, err = o.DisembedFunc(n); err != nil {
+		err = errors.Wrapf(err, "disembedding %s failed", n)
+		return
+	}
+
+	// Unmarshal
+	if err = json.Unmarshal(b, &m); err != nil {
+		err = errors.Wrapf(err, "unmarshaling %s failed", n)
+		return
+	}
+	return
+}
+
+// downloadName returns the name of the download information bound in place of a vendor zip
+func downloadName(zipName string) string {
+	return strings.TrimSuffix(zipName, ".zip") + ".json"
+}
+
+// VerifiedDisembedder returns a disembed function checking the SHA-256 of the disembedded data against the one
+// recorded by the bundler, which is returned by the digest function (e.g. AssetDigest).
+// No digest is recorded for the zips rebuilt at runtime by RepackedDisembedder or downloaded by DownloadDisembedder,
+// the files they're rebuilt or downloaded from are verified instead, therefore both can be wrapped by it.
+func VerifiedDisembedder(disembedFunc func(string) ([]byte, error), digestFunc func(string) ([32]byte, error)) func(string) ([]byte, error) {
+	return func(name string) (o []byte, err error) {
+		// Disembed
+		if o, err = disembedFunc(name); err != nil {
+			return
+		}
+
+		// Get the name whose digest has been recorded
+		var n = name
+		if _, errDigest := digestFunc(name); errDigest != nil {
+			for _, dn := range derivedNames(name) {
+				if _, errDigest = digestFunc(dn); errDigest == nil {
+					n = dn
+					break
+				}
+			}
+		}
+
+		// The file has been rebuilt or downloaded, verify its source
+		var b = o
+		if n != name {
+			if b, err = disembedFunc(n); err != nil {
+				err = errors.Wrapf(err, "disembedding %s failed", n)
+				return
+			}
+		}
+
+		// Get expected digest
+		var e [32]byte
+		if e, err = digestFunc(n); err != nil {
+			err = errors.Wrapf(err, "getting digest of %s failed", n)
+			return
+		}
+
+		// Verify
+		if d := sha256.Sum256(b); d != e {
+			err = corruptedError{digest: d, expected: e, name: n}
+			return
+		}
+		return
+	}
+}
+
+// corruptedError represents a file whose digest doesn't match the one recorded by the bundler
+type corruptedError struct {
+	digest, expected [32]byte
+	name             string
+}
+
+// Error implements the error interface
+func (e corruptedError) Error() string {
+	return fmt.Sprintf("astibundler: %s is corrupted: its sha256 is %x whereas the bundler recorded %x", e.name, e.digest, e.expected)
+}
+
+// derivedNames returns the names of the files a vendor zip can be rebuilt or downloaded from at runtime
+func derivedNames(zipName string) []string {
+	if !strings.HasSuffix(zipName, ".zip") {
+		return nil
+	}
+	return []string{repackedName(zipName, vendorCodecZstd), repackedName(zipName, vendorCodecXZ), downloadName(zipName)}
+}
+
+// NewVerifiedProvisioner builds a disembedder provisioner verifying the vendor zips before they're extracted
+func NewVerifiedProvisioner(disembedFunc func(string) ([]byte, error), digestFunc func(string) ([32]byte, error)) astilectron.Provisioner {
+	return NewProvisioner(VerifiedDisembedder(disembedFunc, digestFunc))
 }
@@ -0,0 +1,200 @@
+package astibundler
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// ConfigurationElectronPrune represents the files removed from the electron zip before it's bound
+type ConfigurationElectronPrune struct {
+	// Locales to keep (e.g. "en-US" or "fr"). A language keeps all its regions, "en" keeps "en-GB" and "en-US".
+	// Default is all of them
+	Locales []string `json:"locales"`
+	// Glob patterns, relative to the root of the electron zip, of the files to remove (e.g. "LICENSES.chromium.html")
+	Paths []string `json:"paths"`
+}
+
+// empty checks whether the pruning rules remove nothing
+func (c ConfigurationElectronPrune) empty() bool {
+	return len(c.Locales) == 0 && len(c.Paths) == 0
+}
+
+// hash returns a hash of the pruning rules, which doesn't depend on their order
+func (c ConfigurationElectronPrune) hash() string {
+	var ls = append([]string{}, c.Locales...)
+	var ps = append([]string{}, c.Paths...)
+	sort.Strings(ls)
+	sort.Strings(ps)
+	var h = sha256.New()
+	fmt.Fprintf(h, "locales %q\npaths %q\n", ls, ps)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pathCacheElectronPruned returns the path of the cached pruned electron vendor zip file
+func (b *Bundler) pathCacheElectronPruned(oS, arch string) string {
+	return fmt.Sprintf("%s-pruned-%s.zip", strings.TrimSuffix(b.pathCacheElectron(oS, arch), ".zip"), b.electronPrune.hash()[:16])
+}
+
+// pathElectron returns the path of the electron vendor zip file to bind
+func (b *Bundler) pathElectron(oS, arch string) string {
+	if b.electronPrune.empty() {
+		return b.pathCacheElectron(oS, arch)
+	}
+	return b.pathCacheElectronPruned(oS, arch)
+}
+
+// locale returns the locale of a file of the electron zip, if any
+// Locales are either stored in "locales/<locale>.pak" files or "<locale>.lproj" folders on darwin
+func locale(name string) (l string, ok bool) {
+	var ss = strings.Split(strings.TrimSuffix(name, "/"), "/")
+	for i, s := range ss {
+		if strings.HasSuffix(s, ".lproj") && s != "Base.lproj" {
+			return strings.Replace(strings.TrimSuffix(s, ".lproj"), "_", "-", -1), true
+		} else if s == "locales" && i == len(ss)-2 && strings.HasSuffix(ss[i+1], ".pak") {
+			return strings.TrimSuffix(ss[i+1], ".pak"), true
+		}
+	}
+	return
+}
+
+// matchLocale checks whether a locale matches one of the locales to keep, either exactly or by language
+func matchLocale(locales []string, l string) bool {
+	for _, k := range locales {
+		if strings.EqualFold(k, l) || strings.HasPrefix(strings.ToLower(l), strings.ToLower(k)+"-") || strings.HasPrefix(strings.ToLower(k), strings.ToLower(l)+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// prune checks whether a file of the electron zip must be removed
+func (c ConfigurationElectronPrune) prune(name string) bool {
+	if matchAny(c.Paths, strings.TrimSuffix(name, "/")) {
+		return true
+	}
+	if l, ok := locale(name); ok && len(c.Locales) > 0 {
+		return !matchLocale(c.Locales, l)
+	}
+	return false
+}
+
+// provisionVendorElectronPruned rewrites the cached electron zip without the pruned files
+func (b *Bundler) provisionVendorElectronPruned(oS, arch string) (err error) {
+	// Nothing to prune or already pruned
+	var src, dst = b.pathCacheElectron(oS, arch), b.pathCacheElectronPruned(oS, arch)
+	if b.electronPrune.empty() {
+		return
+	} else if _, errStat := os.Stat(dst); errStat == nil {
+		astilog.Debugf("%s already exists, skipping pruning of %s", dst, src)
+		return
+	}
+
+	// Open source
+	var r *zip.ReadCloser
+	if r, err = zip.OpenReader(src); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", src)
+		return
+	}
+	defer r.Close()
+
+	// Create destination
+	var tmp = dst + ".tmp"
+	var f *os.File
+	if f, err = os.Create(tmp); err != nil {
+		err = errors.Wrapf(err, "creating %s failed", tmp)
+		return
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	// Copy files that are not pruned without recompressing them
+	astilog.Debugf("Pruning %s into %s", src, dst)
+	var w = zip.NewWriter(f)
+	var removed int
+	for _, zf := range r.File {
+		// Check context error
+		if b.ctx.Err() != nil {
+			return b.ctx.Err()
+		}
+
+		// Prune
+		if b.electronPrune.prune(zf.Name) {
+			removed++
+			continue
+		}
+
+		// Copy
+		if err = copyZipFile(w, zf); err != nil {
+			err = errors.Wrapf(err, "copying %s failed", zf.Name)
+			return
+		}
+	}
+	if err = w.Close(); err != nil {
+		err = errors.Wrapf(err, "closing zip writer of %s failed", tmp)
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s failed", tmp)
+		return
+	}
+
+	// Rename
+	if err = os.Rename(tmp, dst); err != nil {
+		err = errors.Wrapf(err, "renaming %s into %s failed", tmp, dst)
+		return
+	}
+
+	// Log
+	var srcSize, dstSize int64
+	if fi, errStat := os.Stat(src); errStat == nil {
+		srcSize = fi.Size()
+	}
+	if fi, errStat := os.Stat(dst); errStat == nil {
+		dstSize = fi.Size()
+	}
+	astilog.Infof("Pruned %d files from electron %s for %s/%s: %s => %s", removed, astilectron.VersionElectron, oS, arch, humanBytes(srcSize), humanBytes(dstSize))
+	return
+}
+
+// copyZipFile copies a zip file into a zip writer as is
+func copyZipFile(w *zip.Writer, f *zip.File) (err error) {
+	var dst io.Writer
+	var h = f.FileHeader
+	if dst, err = w.CreateRaw(&h); err != nil {
+		err = errors.Wrap(err, "creating raw file failed")
+		return
+	}
+	var src io.Reader
+	if src, err = f.OpenRaw(); err != nil {
+		err = errors.Wrap(err, "opening raw file failed")
+		return
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		err = errors.Wrap(err, "copying raw file failed")
+		return
+	}
+	return
+}
+
+// validateElectronPrune checks that the pruning rules are valid
+func validateElectronPrune(c ConfigurationElectronPrune) error {
+	if err := validatePatterns(c.Paths); err != nil {
+		return err
+	}
+	for _, l := range c.Locales {
+		if len(l) == 0 || strings.ContainsAny(l, "/.") {
+			return fmt.Errorf("locale %q is invalid", l)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package astibundler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/asticode/go-astilog"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz"
+)
+
+// Vendor codecs
+const (
+	vendorCodecXZ   = "xz"
+	vendorCodecZstd = "zstd"
+)
+
+// vendorCodecExtensions indexes the extensions of the repacked vendor archives by codec
+var vendorCodecExtensions = map[string]string{
+	vendorCodecXZ:   ".tar.xz",
+	vendorCodecZstd: ".tar.zst",
+}
+
+// repackedName returns the name of a vendor zip once repacked with a codec
+func repackedName(zipName, codec string) string {
+	return strings.TrimSuffix(zipName, ".zip") + vendorCodecExtensions[codec]
+}
+
+// provisionVendorRepacked repacks a vendor zip as a tar compressed with the vendor codec. The repacked archive is
+// cached next to the zip and only repacked again if the zip is more recent.
+func (b *Bundler) provisionVendorRepacked(src string) (err error) {
+	// Nothing to repack or already repacked
+	var dst = repackedName(src, b.vendorCodec)
+	if len(b.vendorCodec) == 0 {
+		return
+	} else if fiDst, errStat := os.Stat(dst); errStat == nil {
+		if fiSrc, errStat := os.Stat(src); errStat == nil && !fiSrc.ModTime().After(fiDst.ModTime()) {
+			astilog.Debugf("%s already exists, skipping repacking of %s", dst, src)
+			return
+		}
+	}
+
+	// Open source
+	var r *zip.ReadCloser
+	if r, err = zip.OpenReader(src); err != nil {
+		err = errors.Wrapf(err, "opening %s failed", src)
+		return
+	}
+	defer r.Close()
+
+	// Create destination
+	var tmp = dst + ".tmp"
+	var f *os.File
+	if f, err = os.Create(tmp); err != nil {
+		err = errors.Wrapf(err, "creating %s failed", tmp)
+		return
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	// Create compressor
+	var cw io.WriteCloser
+	switch b.vendorCodec {
+	case vendorCodecXZ:
+		if cw, err = xz.NewWriter(f); err != nil {
+			err = errors.Wrap(err, "creating xz writer failed")
+			return
+		}
+	default:
+		if cw, err = zstd.NewWriter(f, zstd.WithEncoderLevel(zstd.SpeedBestCompression)); err != nil {
+			err = errors.Wrap(err, "creating zstd writer failed")
+			return
+		}
+	}
+
+	// Repack
+	astilog.Debugf("Repacking %s into %s", src, dst)
+	if err = b.zipToTar(r.File, tar.NewWriter(cw)); err != nil {
+		err = errors.Wrapf(err, "converting %s to tar failed", src)
+		return
+	}
+	if err = cw.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s writer failed", b.vendorCodec)
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = errors.Wrapf(err, "closing %s failed", tmp)
+		return
+	}
+
+	// Rename
+	if err = os.Rename(tmp, dst); err != nil {
+		err = errors.Wrapf(err, "renaming %s into %s failed", tmp, dst)
+		return
+	}
+
+	// Log
+	var srcSize, dstSize int64
+	if fi, errStat := os.Stat(src); errStat == nil {
+		srcSize = fi.Size()
+	}
+	if fi, errStat := os.Stat(dst); errStat == nil {
+		dstSize = fi.Size()
+	}
+	astilog.Infof("Repacked %s with %s: %s => %s", src, b.vendorCodec, humanBytes(srcSize), humanBytes(dstSize))
+	return
+}
+
+// zipToTar writes zip files into a tar writer, keeping their modes and symlinks
+func (b *Bundler) zipToTar(fs []*zip.File, w *tar.Writer) (err error) {
+	for _, f := range fs {
+		// Check context error
+		if b.ctx.Err() != nil {
+			return b.ctx.Err()
+		}
+
+		// Read content
+		var c []byte
+		if c, err = readZipFile(f); err != nil {
+			err = errors.Wrapf(err, "reading %s failed", f.Name)
+			return
+		}
+
+		// Build header
+		var fi = f.FileInfo()
+		var h = &tar.Header{
+			Mode:     int64(fi.Mode().Perm()),
+			ModTime:  f.Modified,
+			Name:     f.Name,
+			Typeflag: tar.TypeReg,
+		}
+		switch {
+		case fi.IsDir():
+			h.Typeflag = tar.TypeDir
+			c = nil
+		case fi.Mode()&os.ModeSymlink != 0:
+			h.Linkname = string(c)
+			h.Typeflag = tar.TypeSymlink
+			c = nil
+		}
+		h.Size = int64(len(c))
+
+		// Write
+		if err = w.WriteHeader(h); err != nil {
+			err = errors.Wrapf(err, "writing tar header of %s failed", f.Name)
+			return
+		}
+		if _, err = w.Write(c); err != nil {
+			err = errors.Wrapf(err, "writing tar content of %s failed", f.Name)
+			return
+		}
+	}
+	if err = w.Close(); err != nil {
+		err = errors.Wrap(err, "closing tar writer failed")
+		return
+	}
+	return
+}
+
+// readZipFile reads the content of a zip file
+func readZipFile(f *zip.File) (c []byte, err error) {
+	var r io.ReadCloser
+	if r, err = f.Open(); err != nil {
+		err = errors.Wrap(err, "opening failed")
+		return
+	}
+	defer r.Close()
+	if c, err = ioutil.ReadAll(r); err != nil {
+		err = errors.Wrap(err, "reading failed")
+		return
+	}
+	return
+}
+
+// RepackedDisembedder returns a disembed function providing the vendor zips repacked by the bundler when
+// "vendor_codec" is set. The repacked archive is decompressed and zipped again in memory so that it can be
+// extracted by astilectron. Files that have not been repacked are disembedded using the disembed function.
+func RepackedDisembedder(disembedFunc func(string) ([]byte, error)) func(string) ([]byte, error) {
+	return func(name string) (o []byte, err error) {
+		// The file has not been repacked or is not a zip
+		if o, err = disembedFunc(name); err == nil || !strings.HasSuffix(name, ".zip") {
+			return
+		}
+
+		// Loop through codecs
+		for _, codec := range []string{vendorCodecZstd, vendorCodecXZ} {
+			// Disembed
+			var n = repackedName(name, codec)
+			var b []byte
+			var errDisembed error
+			if b, errDisembed = disembedFunc(n); errDisembed != nil {
+				// The repacked archive exists but has been altered
+				if _, ok := errors.Cause(errDisembed).(corruptedError); ok {
+					err = errDisembed
+					return
+				}
+				continue
+			}
+
+			// Convert
+			if o, err = tarToZip(b, codec); err != nil {
+				err = errors.Wrapf(err, "converting %s to zip failed", n)
+				return
+			}
+			return
+		}
+		return
+	}
+}
+
+// tarToZip converts a tar compressed with a codec to an uncompressed zip
+func tarToZip(b []byte, codec string) (o []byte, err error) {
+	// Create decompressor
+	var r io.Reader
+	switch codec {
+	case vendorCodecXZ:
+		if r, err = xz.NewReader(bytes.NewReader(b)); err != nil {
+			err = errors.Wrap(err, "creating xz reader failed")
+			return
+		}
+	case vendorCodecZstd:
+		var d *zstd.Decoder
+		if d, err = zstd.NewReader(bytes.NewReader(b)); err != nil {
+			err = errors.Wrap(err, "creating zstd reader failed")
+			return
+		}
+		defer d.Close()
+		r = d
+	default:
+		err = fmt.Errorf("astibundler: codec %s is invalid", codec)
+		return
+	}
+
+	// Loop through files
+	var buf = &bytes.Buffer{}
+	var w = zip.NewWriter(buf)
+	var tr = tar.NewReader(r)
+	for {
+		// Next
+		var h *tar.Header
+		if h, err = tr.Next(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			err = errors.Wrap(err, "reading tar header failed")
+			return
+		}
+
+		// Build header
+		var zh = &zip.FileHeader{
+			Method:   zip.Store,
+			Modified: h.ModTime,
+			Name:     h.Name,
+		}
+		var c io.Reader = tr
+		switch h.Typeflag {
+		case tar.TypeDir:
+			zh.SetMode(os.ModeDir | os.FileMode(h.Mode).Perm())
+		case tar.TypeSymlink:
+			zh.SetMode(os.ModeSymlink | os.FileMode(h.Mode).Perm())
+			c = strings.NewReader(h.Linkname)
+		default:
+			zh.SetMode(os.FileMode(h.Mode).Perm())
+		}
+
+		// Write
+		var fw io.Writer
+		if fw, err = w.CreateHeader(zh); err != nil {
+			err = errors.Wrapf(err, "creating zip header of %s failed", h.Name)
+			return
+		}
+		if _, err = io.Copy(fw, c); err != nil {
+			err = errors.Wrapf(err, "writing zip content of %s failed", h.Name)
+			return
+		}
+	}
+	if err = w.Close(); err != nil {
+		err = errors.Wrap(err, "closing zip writer failed")
+		return
+	}
+	o = buf.Bytes()
+	return
+}
+
+// NewRepackedProvisioner builds a provisioner decompressing the vendor archives repacked by the bundler when
+// "vendor_codec" is set
+func NewRepackedProvisioner(disembedFunc func(string) ([]byte, error)) astilectron.Provisioner {
+	return NewProvisioner(RepackedDisembedder(disembedFunc))
+}
@@ -0,0 +1,235 @@
+package astibundler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// testZipFile represents a file of a test zip
+type testZipFile struct {
+	content string
+	mode    os.FileMode
+	name    string
+}
+
+var testZipFiles = []testZipFile{
+	{mode: os.ModeDir | 0755, name: "Electron.app/"},
+	{content: "binary", mode: 0755, name: "Electron.app/electron"},
+	{content: "Versions/Current/Electron Framework", mode: os.ModeSymlink | 0777, name: "Electron.app/Electron Framework"},
+	{content: "license", mode: 0644, name: "LICENSE"},
+}
+
+func newTestZip(t *testing.T) *zip.Reader {
+	var buf = &bytes.Buffer{}
+	var w = zip.NewWriter(buf)
+	for _, f := range testZipFiles {
+		var h = &zip.FileHeader{Method: zip.Deflate, Name: f.name}
+		h.SetMode(f.mode)
+		fw, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatalf("creating %s failed: %s", f.name, err)
+		}
+		if _, err = io.WriteString(fw, f.content); err != nil {
+			t.Fatalf("writing %s failed: %s", f.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing zip writer failed: %s", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading zip failed: %s", err)
+	}
+	return r
+}
+
+func checkTestZip(t *testing.T, b []byte) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("reading zip failed: %s", err)
+	}
+	if len(r.File) != len(testZipFiles) {
+		t.Fatalf("expected %d files, got %d", len(testZipFiles), len(r.File))
+	}
+	for i, e := range testZipFiles {
+		var f = r.File[i]
+		if f.Name != e.name {
+			t.Errorf("expected name %s, got %s", e.name, f.Name)
+		}
+		if m := f.Mode(); m != e.mode {
+			t.Errorf("expected mode %s for %s, got %s", e.mode, e.name, m)
+		}
+		c, err := readZipFile(f)
+		if err != nil {
+			t.Errorf("reading %s failed: %s", e.name, err)
+		} else if string(c) != e.content {
+			t.Errorf("expected content %q for %s, got %q", e.content, e.name, c)
+		}
+	}
+}
+
+func TestRepack(t *testing.T) {
+	for _, codec := range []string{vendorCodecXZ, vendorCodecZstd} {
+		t.Run(codec, func(t *testing.T) {
+			// Create compressor
+			var buf = &bytes.Buffer{}
+			var cw io.WriteCloser
+			var err error
+			switch codec {
+			case vendorCodecXZ:
+				cw, err = xz.NewWriter(buf)
+			default:
+				cw, err = zstd.NewWriter(buf)
+			}
+			if err != nil {
+				t.Fatalf("creating %s writer failed: %s", codec, err)
+			}
+
+			// Zip to tar
+			var b = &Bundler{ctx: context.Background()}
+			var tb = &bytes.Buffer{}
+			if err = b.zipToTar(newTestZip(t).File, tar.NewWriter(io.MultiWriter(cw, tb))); err != nil {
+				t.Fatalf("converting zip to tar failed: %s", err)
+			}
+			if err = cw.Close(); err != nil {
+				t.Fatalf("closing %s writer failed: %s", codec, err)
+			}
+
+			// Check tar
+			var tr = tar.NewReader(tb)
+			for _, e := range testZipFiles {
+				h, err := tr.Next()
+				if err != nil {
+					t.Fatalf("reading tar header of %s failed: %s", e.name, err)
+				}
+				switch {
+				case e.mode.IsDir():
+					if h.Typeflag != tar.TypeDir {
+						t.Errorf("expected %s to be a dir", e.name)
+					}
+				case e.mode&os.ModeSymlink != 0:
+					if h.Typeflag != tar.TypeSymlink || h.Linkname != e.content {
+						t.Errorf("expected %s to be a symlink to %s, got type %c and link %s", e.name, e.content, h.Typeflag, h.Linkname)
+					}
+				default:
+					if h.Typeflag != tar.TypeReg || h.Size != int64(len(e.content)) {
+						t.Errorf("expected %s to be a %d bytes file, got type %c and size %d", e.name, len(e.content), h.Typeflag, h.Size)
+					}
+				}
+			}
+
+			// Tar to zip
+			var o []byte
+			if o, err = tarToZip(buf.Bytes(), codec); err != nil {
+				t.Fatalf("converting tar to zip failed: %s", err)
+			}
+			checkTestZip(t, o)
+		})
+	}
+}
+
+func TestRepackedDisembedder(t *testing.T) {
+	// Repack
+	var buf = &bytes.Buffer{}
+	cw, err := zstd.NewWriter(buf)
+	if err != nil {
+		t.Fatalf("creating zstd writer failed: %s", err)
+	}
+	var b = &Bundler{ctx: context.Background()}
+	if err = b.zipToTar(newTestZip(t).File, tar.NewWriter(cw)); err != nil {
+		t.Fatalf("converting zip to tar failed: %s", err)
+	}
+	if err = cw.Close(); err != nil {
+		t.Fatalf("closing zstd writer failed: %s", err)
+	}
+
+	// Disembed
+	var fs = map[string][]byte{
+		"vendor/electron.tar.zst": buf.Bytes(),
+		"vendor/astilectron.zip":  []byte("astilectron"),
+	}
+	var d = RepackedDisembedder(func(name string) ([]byte, error) {
+		if b, ok := fs[name]; ok {
+			return b, nil
+		}
+		return nil, os.ErrNotExist
+	})
+	var o []byte
+	if o, err = d("vendor/electron.zip"); err != nil {
+		t.Fatalf("disembedding repacked zip failed: %s", err)
+	}
+	checkTestZip(t, o)
+	if o, err = d("vendor/astilectron.zip"); err != nil || string(o) != "astilectron" {
+		t.Errorf("expected zip that has not been repacked, got %q and %v", o, err)
+	}
+	if _, err = d("vendor/unknown.zip"); err == nil {
+		t.Error("expected an error for an unknown zip")
+	}
+}
+
+func TestVerifiedRepackedDisembedder(t *testing.T) {
+	// Repack
+	var buf = &bytes.Buffer{}
+	cw, err := zstd.NewWriter(buf)
+	if err != nil {
+		t.Fatalf("creating zstd writer failed: %s", err)
+	}
+	var b = &Bundler{ctx: context.Background()}
+	if err = b.zipToTar(newTestZip(t).File, tar.NewWriter(cw)); err != nil {
+		t.Fatalf("converting zip to tar failed: %s", err)
+	}
+	if err = cw.Close(); err != nil {
+		t.Fatalf("closing zstd writer failed: %s", err)
+	}
+
+	// Digests are only recorded for the repacked archive
+	var fs = map[string][]byte{"vendor/electron.tar.zst": buf.Bytes()}
+	var ds = map[string][32]byte{"vendor/electron.tar.zst": sha256.Sum256(buf.Bytes())}
+	var disembedFunc = func(name string) ([]byte, error) {
+		if b, ok := fs[name]; ok {
+			return b, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	var digestFunc = func(name string) ([32]byte, error) {
+		if d, ok := ds[name]; ok {
+			return d, nil
+		}
+		return [32]byte{}, os.ErrNotExist
+	}
+
+	// Both orders can be used
+	for _, c := range []struct {
+		name string
+		fn   func(string) ([]byte, error)
+	}{
+		{name: "verified inside", fn: RepackedDisembedder(VerifiedDisembedder(disembedFunc, digestFunc))},
+		{name: "verified outside", fn: VerifiedDisembedder(RepackedDisembedder(disembedFunc), digestFunc)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			// Valid
+			o, err := c.fn("vendor/electron.zip")
+			if err != nil {
+				t.Fatalf("disembedding failed: %s", err)
+			}
+			checkTestZip(t, o)
+
+			// Corrupted
+			ds["vendor/electron.tar.zst"] = [32]byte{}
+			defer func() { ds["vendor/electron.tar.zst"] = sha256.Sum256(buf.Bytes()) }()
+			if _, err = c.fn("vendor/electron.zip"); err == nil || !strings.Contains(err.Error(), "corrupted") {
+				t.Errorf("expected a corruption error, got %v", err)
+			}
+		})
+	}
+}
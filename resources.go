@@ -0,0 +1,338 @@
+package astibundler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astitools/os"
+	"github.com/pkg/errors"
+)
+
+// resourcesIgnoreName is the name of the file, in the input path, listing the resources not to bind
+const resourcesIgnoreName = ".bundlerignore"
+
+// resourcesPath represents a folder whose files are bound as resources
+type resourcesPath struct {
+	optional bool
+	path     string
+	prefix   string // Slash separated path relative to the resources folder
+}
+
+// asset represents a file to bind
+type asset struct {
+	name   string // Slash separated name of the asset in the bind data (e.g. "resources/index.html")
+	path   string // Path of the file on disk
+	vendor bool   // Whether the asset is provided by the bundler rather than the project
+}
+
+// validatePatterns validates glob patterns
+func validatePatterns(ps []string) error {
+	for _, p := range ps {
+		for _, s := range strings.Split(p, "/") {
+			if _, err := path.Match(s, ""); err != nil {
+				return errors.Wrapf(err, "pattern %s is invalid", p)
+			}
+		}
+	}
+	return nil
+}
+
+// matchPattern checks whether a slash separated relative path matches a glob pattern.
+// Patterns without slash, such as "*.psd" or "node_modules", match any component of the path. Other patterns,
+// such as "test/fixtures" or "js/**/*.map", match the path or one of its parent dirs relatively to the root.
+func matchPattern(pattern, rel string) bool {
+	var ss = strings.Split(rel, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, s := range ss {
+			if ok, _ := path.Match(pattern, s); ok {
+				return true
+			}
+		}
+		return false
+	}
+	var ps = strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	for i := 1; i <= len(ss); i++ {
+		if matchSegments(ps, ss[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments checks whether path segments match pattern segments, "**" matching any number of segments
+func matchSegments(ps, ss []string) bool {
+	if len(ps) == 0 {
+		return len(ss) == 0
+	}
+	if ps[0] == "**" {
+		for i := 0; i <= len(ss); i++ {
+			if matchSegments(ps[1:], ss[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(ss) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(ps[0], ss[0]); !ok {
+		return false
+	}
+	return matchSegments(ps[1:], ss[1:])
+}
+
+// matchAny checks whether a slash separated relative path matches any of the glob patterns
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// readIgnoreFile reads the patterns listed in the .bundlerignore file, if any
+func (b *Bundler) readIgnoreFile() (ps []string, err error) {
+	// Open file
+	var p = filepath.Join(b.pathInput, resourcesIgnoreName)
+	var f *os.File
+	if f, err = os.Open(p); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = errors.Wrapf(err, "opening %s failed", p)
+		return
+	}
+	defer f.Close()
+
+	// Loop through lines
+	var s = bufio.NewScanner(f)
+	for s.Scan() {
+		var l = strings.TrimSpace(s.Text())
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+		ps = append(ps, l)
+	}
+	if err = s.Err(); err != nil {
+		err = errors.Wrapf(err, "scanning %s failed", p)
+		return
+	}
+
+	// Validate
+	if err = validatePatterns(ps); err != nil {
+		err = errors.Wrapf(err, "validating patterns of %s failed", p)
+		return
+	}
+	return
+}
+
+// resourceAssets returns the resources to bind for an environment.
+// Files of the <folder>_<os> and <folder>_<os>_<arch> overlay folders, if any, replace or extend the files of each
+// resources folder.
+func (b *Bundler) resourceAssets(oS, arch string) (as []asset, err error) {
+	// Build exclude patterns
+	var excludes []string
+	if excludes, err = b.readIgnoreFile(); err != nil {
+		err = errors.Wrap(err, "reading ignore file failed")
+		return
+	}
+	excludes = append(excludes, b.resourcesExclude...)
+
+	// Loop through layers
+	var layers = make(map[string]asset)
+	for _, suffix := range []string{"", "_" + oS, "_" + oS + "_" + arch} {
+		// Loop through resources paths
+		var names = make(map[string]string)
+		for _, r := range b.pathResources {
+			// Make sure the folder exists
+			var p = r.path + suffix
+			if _, errStat := os.Stat(p); os.IsNotExist(errStat) {
+				if r.optional || len(suffix) > 0 {
+					continue
+				}
+				err = fmt.Errorf("resources folder %s doesn't exist", p)
+				return
+			}
+
+			// Walk
+			var ras []asset
+			if ras, err = walkAssets(p, path.Join("resources", r.prefix), func(rel string, dir bool) bool {
+				rel = path.Join(r.prefix, rel)
+				if dir {
+					// Exclude patterns matching a folder match all its files, unlike include patterns
+					if matchAny(excludes, rel) {
+						astilog.Debugf("Excluding resources folder %s of %s since it matches an exclude pattern", rel, p)
+						return false
+					}
+					return true
+				}
+				if len(b.resourcesInclude) > 0 && !matchAny(b.resourcesInclude, rel) {
+					astilog.Debugf("Excluding resource %s of %s since it matches no include pattern", rel, p)
+					return false
+				}
+				if matchAny(excludes, rel) {
+					astilog.Debugf("Excluding resource %s of %s since it matches an exclude pattern", rel, p)
+					return false
+				}
+				return true
+			}); err != nil {
+				err = errors.Wrapf(err, "walking through %s failed", p)
+				return
+			}
+
+			// Loop through assets
+			for _, a := range ras {
+				// Detect conflicts within the layer
+				if o, ok := names[a.name]; ok {
+					err = fmt.Errorf("%s is provided by both %s and %s", a.name, o, a.path)
+					return
+				}
+				names[a.name] = a.path
+
+				// Override previous layers
+				if l, ok := layers[a.name]; ok {
+					astilog.Debugf("Overriding %s with %s", l.path, a.path)
+				}
+				layers[a.name] = a
+			}
+		}
+	}
+
+	// Sort
+	for _, a := range layers {
+		as = append(as, a)
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].name < as[j].name })
+	return
+}
+
+// vendorAssets returns the vendor files to bind. They're bound from the cache so that the project's own
+// vendor folder is never touched. In download mode, the electron zip is replaced by its download information.
+func (b *Bundler) vendorAssets(oS, arch string) []asset {
+	var as = []asset{b.vendorAsset(b.vendorNames.Astilectron, b.pathCacheAstilectron())}
+	if b.vendorMode == vendorModeDownload {
+		return append(as, asset{name: path.Join(b.vendorNames.Prefix, downloadName(b.vendorNames.Electron)), path: b.pathCacheElectronDownload(oS, arch), vendor: true})
+	}
+	return append(as, b.vendorAsset(b.vendorNames.Electron, b.pathElectron(oS, arch)))
+}
+
+// vendorAsset returns the asset of a vendor zip, repacked if a vendor codec is set
+func (b *Bundler) vendorAsset(name, p string) asset {
+	if len(b.vendorCodec) > 0 {
+		name, p = repackedName(name, b.vendorCodec), repackedName(p, b.vendorCodec)
+	}
+	return asset{name: path.Join(b.vendorNames.Prefix, name), path: p, vendor: true}
+}
+
+// walkAssets returns the files of a root dir accepted by the filter as assets whose names are prefixed.
+// Dirs rejected by the filter are skipped.
+func walkAssets(root, prefix string, filter func(rel string, dir bool) bool) (as []asset, err error) {
+	if err = filepath.Walk(root, func(p string, info os.FileInfo, e error) (err error) {
+		// Check error
+		if e != nil {
+			if os.IsNotExist(e) && p == root {
+				return nil
+			}
+			return e
+		}
+
+		// Root
+		if p == root && info.IsDir() {
+			return nil
+		}
+
+		// Filter
+		var rel string
+		if rel, err = filepath.Rel(root, p); err != nil {
+			return errors.Wrapf(err, "filepath.Rel of %s and %s failed", root, p)
+		}
+		rel = filepath.ToSlash(rel)
+		if filter != nil && !filter(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Only add files
+		if info.IsDir() {
+			return nil
+		}
+		as = append(as, asset{name: path.Join(prefix, rel), path: p})
+		return nil
+	}); err != nil {
+		return
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].name < as[j].name })
+	return
+}
+
+// stageAssets writes assets in a dir under their name, hard linking them when possible.
+// If compress is true, assets are gzipped according to their compression level and the names of the compressed
+// assets are returned.
+func (b *Bundler) stageAssets(as []asset, dir string, compress bool) (r bindReport, compressed []string, err error) {
+	r = make(bindReport)
+	for _, a := range as {
+		// Stat
+		var fi os.FileInfo
+		if fi, err = os.Stat(a.path); err != nil {
+			err = errors.Wrapf(err, "stating %s failed", a.path)
+			return
+		}
+
+		// Create dir
+		var p = filepath.Join(dir, filepath.FromSlash(a.name))
+		if err = os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			err = errors.Wrapf(err, "mkdirall %s failed", filepath.Dir(p))
+			return
+		}
+
+		// Compress
+		var n = time.Now()
+		if l := b.compressionLevel(a); compress && l != gzip.NoCompression {
+			if err = gzipFile(a.path, p, l); err != nil {
+				err = errors.Wrapf(err, "gzipping %s into %s failed", a.path, p)
+				return
+			}
+			var cfi os.FileInfo
+			if cfi, err = os.Stat(p); err != nil {
+				err = errors.Wrapf(err, "stating %s failed", p)
+				return
+			}
+			compressed = append(compressed, a.name)
+			r.add(a, fmt.Sprintf("gzip level %d", l), fi.Size(), cfi.Size(), time.Since(n))
+			continue
+		}
+
+		// Link
+		if errLink := os.Link(a.path, p); errLink == nil {
+			r.add(a, "none", fi.Size(), fi.Size(), time.Since(n))
+			continue
+		}
+
+		// Copy
+		if err = astios.Copy(b.ctx, a.path, p); err != nil {
+			err = errors.Wrapf(err, "copying %s to %s failed", a.path, p)
+			return
+		}
+		r.add(a, "none", fi.Size(), fi.Size(), time.Since(n))
+
+		// Check context error
+		if b.ctx.Err() != nil {
+			err = b.ctx.Err()
+			return
+		}
+	}
+	return
+}